@@ -4,12 +4,13 @@ import (
 	"context"
 	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"log"
 	"os"
 	"path/filepath"
 	"sort"
+	"sync"
 	"time"
 
 	"github.com/wailsapp/wails/v2/pkg/runtime"
@@ -18,19 +19,23 @@ import (
 	sph "sphaeroptica.be/photogrammetry/photogrammetry"
 )
 
+// distance (in pixels) to the border under which a reprojection is near the edge of the image
+const EDGE_MARGIN = 20
+
 // App struct
 type App struct {
-	ctx              context.Context
-	Path             string
-	Project          *project
+	ctx      context.Context
+	projects *registry
+
+	// guards the directory of the dialogs
+	mutex            sync.Mutex
 	DefaultDirectory string
 }
 
 // NewApp creates a new App application struct
 func NewApp() *App {
 	return &App{
-		Path:             "",
-		Project:          nil,
+		projects:         newRegistry(),
 		DefaultDirectory: "",
 	}
 }
@@ -80,23 +85,18 @@ var IMPORTS_FILES = map[string][]ImportFile{
 	},
 }
 
-var IMPORTS_READER = map[string]func(map[string]string) (*project, string, []imp.SaveThumbnail){
+var IMPORTS_READER = map[string]func(map[string]string) (*project, string, []imp.SaveThumbnail, error){
 	"Metashape": ReadMetashape,
 }
 
-func ReadMetashape(files map[string]string) (*project, string, []imp.SaveThumbnail) {
+func ReadMetashape(files map[string]string) (*project, string, []imp.SaveThumbnail, error) {
 	log.Println("Read Metashape Log")
 	if len(files) != len(IMPORTS_FILES["Metashape"]) {
-		log.Printf("File length incorrect\n")
-		log.Printf("%d, %d\n", len(files), len(IMPORTS_FILES["Metashape"]))
-		return nil, "", nil
+		return nil, "", nil, fmt.Errorf("%d files expected, not %d", len(IMPORTS_FILES["Metashape"]), len(files))
 	}
 	for _, importFile := range IMPORTS_FILES["Metashape"] {
 		if _, ok := files[importFile.Name]; !ok || len(files[importFile.Name]) == 0 {
-			log.Printf("File Names incorrect\n")
-			log.Printf("%sn", importFile.Name)
-			log.Printf("%sn", files[importFile.Name])
-			return nil, "", nil
+			return nil, "", nil, fmt.Errorf("missing %s", importFile.Label)
 		}
 	}
 
@@ -105,43 +105,42 @@ func ReadMetashape(files map[string]string) (*project, string, []imp.SaveThumbna
 
 	thumbnails := fmt.Sprintf("%s/%s", imagesDir, thumbnailsDir)
 	if err := os.MkdirAll(thumbnails, os.ModePerm); err != nil {
-		log.Println(err)
-		return nil, "", nil
+		return nil, "", nil, err
 	}
 
 	images, thumbWidth, thumbHeight, thumbCreate, err := imp.ReadChildImages(imagesDir, thumbnailsDir)
 	if err != nil {
-		log.Println(err)
-		return nil, "", nil
+		return nil, "", nil, err
 	}
 
 	intrinsics, err := imp.ReadIntrinsicMetashape(files["Intrinsics"])
 	if err != nil {
-		log.Println(err)
-		return nil, "", nil
+		return nil, "", nil, err
 	}
 
 	extrinsics, latMin, latMax, err := imp.ReadExtrinsicMetashape(files["Extrinsics"], images)
 	if err != nil {
-		log.Println(err)
-		return nil, "", nil
+		return nil, "", nil, err
+	}
+
+	commands, commandsOrder := defaultCommands(latMin, latMax)
+
+	// labels of the cameras in Metashape, by image
+	cameraLabels := make(map[string]string)
+	for label, image := range images {
+		cameraLabels[image] = label
 	}
 
 	return &project{
-		Commands: map[string]sph.Coordinates{
-			"FRONT":    {Longitude: 0, Latitude: 0},
-			"POST":     {Longitude: 180, Latitude: 0},
-			"LEFT":     {Longitude: 90, Latitude: 0},
-			"RIGHT":    {Longitude: -90, Latitude: 0},
-			"SUPERIOR": {Longitude: 0, Latitude: latMin},
-			"INFERIOR": {Longitude: 180, Latitude: latMax},
-		},
+		Commands:         commands,
+		CommandsOrder:    commandsOrder,
 		Intrinsics:       *intrinsics,
 		Extrinsics:       extrinsics,
+		CameraLabels:     cameraLabels,
 		Thumbnails:       thumbnailsDir,
 		ThumbnailsWidth:  thumbWidth,
 		ThumbnailsHeight: thumbHeight,
-	}, imagesDir, thumbCreate
+	}, imagesDir, thumbCreate, nil
 }
 
 func (a *App) GetImportMethods() map[string][]ImportForm {
@@ -160,14 +159,20 @@ func (a *App) GetImportMethods() map[string][]ImportForm {
 	return imports
 }
 
-func (a *App) ImportProject(software string, files map[string]string) string {
+func (a *App) ImportProject(software string, files map[string]string) (string, error) {
 	log.Printf("Import Project from %s\n", software)
-	project, imagesDir, thumbCreate := IMPORTS_READER[software](files)
+	reader, ok := IMPORTS_READER[software]
+	if !ok {
+		return "", errorf(ERROR_INVALID_ARGUMENT, "unknown software %s", software)
+	}
+	project, imagesDir, thumbCreate, err := reader(files)
+	if err != nil {
+		return "", newError(ERROR_FILE, err)
+	}
 
 	data, err := json.MarshalIndent(project, "", "  ")
 	if err != nil {
-		log.Println(err)
-		return ""
+		return "", newError(ERROR_PROJECT, err)
 	}
 
 	path, err := runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
@@ -179,29 +184,30 @@ func (a *App) ImportProject(software string, files map[string]string) string {
 		}},
 	})
 	if err != nil {
-		log.Println(err)
-		return ""
+		return "", newError(ERROR_FILE, err)
+	}
+	if path == "" {
+		return "", nil
 	}
 	thumbPath := fmt.Sprintf("%s/%s", imagesDir, project.Thumbnails)
 	project.ThumbnailsWidth, project.ThumbnailsHeight, err = imp.CreateThumbnails(thumbPath, thumbCreate, project.ThumbnailsWidth, project.ThumbnailsHeight)
 	if err != nil {
-		log.Println("Error while creating thumbnails")
-		log.Println(err)
-		return ""
+		return "", errorf(ERROR_FILE, "error while creating thumbnails : %w", err)
 	}
 	err = os.WriteFile(path, data, 0644)
 	if err != nil {
-		log.Println(err)
-		return ""
+		return "", newError(ERROR_PROJECT, err)
 	}
 
-	a.Path = path
-	a.Project = project
+	a.projects.add(path, project)
 
-	return path
+	return path, nil
 }
 
-func (a *App) OpenImportFile(software string, index int) string {
+func (a *App) OpenImportFile(software string, index int) (string, error) {
+	if index < 0 || index >= len(IMPORTS_FILES[software]) {
+		return "", errorf(ERROR_INVALID_ARGUMENT, "no file %d to import from %s", index, software)
+	}
 	importFile := IMPORTS_FILES[software][index]
 	str := ""
 
@@ -212,143 +218,199 @@ func (a *App) OpenImportFile(software string, index int) string {
 		str = a.openDirectoryDialog("Select "+importFile.Label, importFile.Filters)
 	}
 
-	return str
+	return str, nil
 }
 
-func (a *App) Reproject(projectFile string, imageName string, position []float64) sph.Pos {
-	if a.Path != projectFile {
-		err := a.loadProjectFile(projectFile)
-		if err != nil {
-			log.Println(err)
-			return sph.Pos{X: -1, Y: -1}
+func (a *App) Reproject(projectFile string, imageName string, position []float64) (Reprojection, error) {
+	p, release, err := a.acquire(projectFile)
+	if err != nil {
+		return Reprojection{Pose: sph.Pos{X: -1, Y: -1}}, newError(ERROR_PROJECT, err)
+	}
+	defer release()
+	if err := p.checkImages(imageName); err != nil {
+		return Reprojection{Pose: sph.Pos{X: -1, Y: -1}}, newError(ERROR_UNKNOWN_IMAGE, err)
+	}
+	if len(position) < 3 {
+		return Reprojection{Pose: sph.Pos{X: -1, Y: -1}}, errorf(ERROR_INVALID_ARGUMENT, "the landmark isn't triangulated")
+	}
+	return p.reproject(imageName, position), nil
+}
+
+// Reproject every landmark (by label) on the images (every image if none is given) in one call
+func (a *App) ReprojectLandmarks(projectFile string, positions map[string][]float64, images []string) (map[string]map[string]Reprojection, error) {
+	reprojections := make(map[string]map[string]Reprojection)
+	p, release, err := a.acquire(projectFile)
+	if err != nil {
+		return reprojections, newError(ERROR_PROJECT, err)
+	}
+	defer release()
+	if err := p.checkImages(images...); err != nil {
+		return reprojections, newError(ERROR_UNKNOWN_IMAGE, err)
+	}
+	if len(images) == 0 {
+		for image := range p.Extrinsics {
+			images = append(images, image)
+		}
+	}
+	for label, position := range positions {
+		if len(position) < 3 {
+			continue
+		}
+		reprojections[label] = make(map[string]Reprojection, len(images))
+		for _, image := range images {
+			reprojections[label][image] = p.reproject(image, position)
 		}
 	}
-	vectorPos := mat.NewVecDense(4, position)
+	return reprojections, nil
+}
 
-	intrinsics := mat.NewDense(a.Project.Intrinsics.CameraMatrix.Shape.Row, a.Project.Intrinsics.CameraMatrix.Shape.Col, a.Project.Intrinsics.CameraMatrix.Data)
-	distCoeffs := mat.NewDense(a.Project.Intrinsics.DistortionMatrix.Shape.Row, a.Project.Intrinsics.DistortionMatrix.Shape.Col, a.Project.Intrinsics.DistortionMatrix.Data)
-	extrinsics := mat.NewDense(a.Project.Extrinsics[imageName].Matrix.Shape.Row, a.Project.Extrinsics[imageName].Matrix.Shape.Col, a.Project.Extrinsics[imageName].Matrix.Data)
+// reproject projects the point on the image and tells where it falls,
+// the pose is (-1, -1) if the point is behind the camera
+func (p *project) reproject(imageName string, position []float64) Reprojection {
+	vectorPos := mat.NewVecDense(4, []float64{position[0], position[1], position[2], 1})
+
+	matrices := p.matrices()
+	extrinsics, ok := matrices.extrinsics[imageName]
+	if !ok || mat.Dot(extrinsics.RowView(2), vectorPos) <= 0 {
+		return Reprojection{Pose: sph.Pos{X: -1, Y: -1}}
+	}
+	pos := sph.ProjectWithMatrix(vectorPos, matrices.projections[imageName], matrices.intrinsics, matrices.distCoeffs)
+	width, height := float64(p.Intrinsics.Width), float64(p.Intrinsics.Height)
+	inside := pos.X >= 0 && pos.Y >= 0 && pos.X < width && pos.Y < height
+	nearEdge := inside && (pos.X < EDGE_MARGIN || pos.Y < EDGE_MARGIN || pos.X >= width-EDGE_MARGIN || pos.Y >= height-EDGE_MARGIN)
+	return Reprojection{Pose: pos, InFront: true, Inside: inside, NearEdge: nearEdge}
+}
+
+// reprojectVisible projects the point on the image, ok is false if it is behind the camera or outside the image
+func (p *project) reprojectVisible(imageName string, position []float64) (sph.Pos, bool) {
+	reprojection := p.reproject(imageName, position)
+	return reprojection.Pose, reprojection.Inside
+}
 
-	return sph.ProjectPoints(vectorPos, intrinsics, extrinsics, distCoeffs)
+func (a *App) Triangulate(projectFile string, poses map[string]sph.Pos) ([]float64, error) {
+	p, release, err := a.acquire(projectFile)
+	if err != nil {
+		return []float64{}, newError(ERROR_PROJECT, err)
+	}
+	defer release()
+	return p.triangulate(poses)
 }
 
-func (a *App) Triangulate(projectFile string, poses map[string]sph.Pos) []float64 {
-	if a.Path != projectFile {
-		err := a.loadProjectFile(projectFile)
+// Triangulate every landmark (by label) from its poses in one call
+func (a *App) TriangulateLandmarks(projectFile string, poses map[string]map[string]sph.Pos) (map[string][]float64, error) {
+	positions := make(map[string][]float64)
+	p, release, err := a.acquire(projectFile)
+	if err != nil {
+		return positions, newError(ERROR_PROJECT, err)
+	}
+	defer release()
+	for label, landmarkPoses := range poses {
+		position, err := p.triangulate(landmarkPoses)
+		if errors.Is(err, errNotEnoughPoses) {
+			// not placed yet
+			continue
+		}
 		if err != nil {
-			log.Println(err)
-			return []float64{}
+			return map[string][]float64{}, err
 		}
+		positions[label] = position
 	}
+	return positions, nil
+}
 
-	intrinsics := mat.NewDense(a.Project.Intrinsics.CameraMatrix.Shape.Row, a.Project.Intrinsics.CameraMatrix.Shape.Col, a.Project.Intrinsics.CameraMatrix.Data)
-	distCoeffs := mat.NewDense(a.Project.Intrinsics.DistortionMatrix.Shape.Row, a.Project.Intrinsics.DistortionMatrix.Shape.Col, a.Project.Intrinsics.DistortionMatrix.Data)
+var errNotEnoughPoses = errors.New("the landmark must be placed on 2 images at least")
 
+// triangulate computes the position of the landmark from its poses
+func (p *project) triangulate(poses map[string]sph.Pos) ([]float64, error) {
+	if len(poses) < 2 {
+		return []float64{}, newError(ERROR_INVALID_ARGUMENT, errNotEnoughPoses)
+	}
+	matrices := p.matrices()
 	projPoints := make([]sph.ProjPoint, 0)
 
 	for image, pos := range poses {
-		extrinsics := mat.NewDense(a.Project.Extrinsics[image].Matrix.Shape.Row, a.Project.Extrinsics[image].Matrix.Shape.Col, a.Project.Extrinsics[image].Matrix.Data)
-		projMat := sph.ProjectionMatrix(intrinsics, extrinsics)
+		projMat, ok := matrices.projections[image]
+		if !ok {
+			return []float64{}, newError(ERROR_UNKNOWN_IMAGE, p.checkImages(image))
+		}
 		pose := mat.NewVecDense(2, []float64{pos.X, pos.Y})
-		undistortedPos := sph.UndistortIter(pose, intrinsics, distCoeffs)
+		undistortedPos := sph.UndistortIter(pose, matrices.intrinsics, matrices.distCoeffs)
 
 		projPoints = append(projPoints, sph.ProjPoint{Mat: projMat, Point: undistortedPos})
 	}
 
 	landmarkPos := sph.TriangulatePoint(projPoints)
-	return landmarkPos
+	return landmarkPos, nil
 }
 
 // Get shortcuts
-func (a *App) Shortcuts(projectFile string) map[string]sph.Coordinates {
-	if a.Path != projectFile {
-		err := a.loadProjectFile(projectFile)
-		if err != nil {
-			log.Println(err)
-			return map[string]sph.Coordinates{}
-		}
+func (a *App) Shortcuts(projectFile string) (map[string]sph.Coordinates, error) {
+	p, release, err := a.acquire(projectFile)
+	if err != nil {
+		return map[string]sph.Coordinates{}, newError(ERROR_PROJECT, err)
 	}
+	defer release()
 
-	return a.Project.Commands
+	return p.Commands, nil
 }
 
 // Get images
-func (a *App) Images(projectFile string) *CameraViewer {
-	if a.Path != projectFile {
-		err := a.loadProjectFile(projectFile)
-		if err != nil {
-			log.Println(err)
-			return nil
-		}
+func (a *App) Images(projectFile string) (*CameraViewer, error) {
+	p, release, err := a.acquire(projectFile)
+	if err != nil {
+		return nil, newError(ERROR_PROJECT, err)
 	}
+	defer release()
 
-	log.Printf("Project : \n%v\n", a.Project)
+	log.Printf("Project : \n%v\n", p)
 
-	keys := make([]string, 0, len(a.Project.Extrinsics))
+	keys := make([]string, 0, len(p.Extrinsics))
 
-	for k := range a.Project.Extrinsics {
+	for k := range p.Extrinsics {
 		keys = append(keys, k)
 	}
 	sort.Strings(keys)
 
-	centers := make(map[string]mat.Vector)
-
-	var centersX []float64
-	var centersY []float64
-	var centersZ []float64
-
 	encodedImages := make([]VirtualCameraImage, 0)
 
 	thumbnails := false
+	projectDirAbs := projectRoot(projectFile)
 	for _, image := range keys {
-		projectDirAbs, _ := filepath.Abs(filepath.Dir(projectFile))
 		file := fmt.Sprintf("%s/%s", projectDirAbs, image)
 		thumbnail := ""
-		if a.Project.Thumbnails != "" {
-			thumbnail = fmt.Sprintf("%s/%s/%s", projectDirAbs, a.Project.Thumbnails, image)
+		if p.Thumbnails != "" {
+			thumbnail = fmt.Sprintf("%s/%s/%s", projectDirAbs, p.Thumbnails, image)
 			thumbnails = true
 		}
+		// archives can be shared without (some of) the full images
+		fullImage := !isArchive(projectFile) || archiveHas(projectFile, image)
+		if !fullImage && thumbnail != "" {
+			file = thumbnail
+		}
 		encodedImages = append(encodedImages, VirtualCameraImage{Name: image, FullImage: file, Thumbnail: thumbnail})
-
-		extrinsics := a.Project.Extrinsics[image]
-
-		extrinsicsMat := mat.NewDense(extrinsics.Matrix.Shape.Row, extrinsics.Matrix.Shape.Col, extrinsics.Matrix.Data)
-		rotationMat := mat.DenseCopyOf(extrinsicsMat.Slice(0, 3, 0, 3))
-		transMat := mat.DenseCopyOf(extrinsicsMat.Slice(0, 3, 3, 4))
-		worldCoord := sph.GetCameraWorldsCoordinates(rotationMat, transMat)
-		centersX = append(centersX, worldCoord.AtVec(0))
-		centersY = append(centersY, worldCoord.AtVec(1))
-		centersZ = append(centersZ, worldCoord.AtVec(2))
-		centers[image] = worldCoord
 	}
 
-	_, center := sph.SphereFit(centersX, centersY, centersZ)
-
-	var centerVecDense mat.VecDense
-	centerVecDense.CloneFromVec(center)
-	centerVec := centerVecDense.SliceVec(0, 3)
-	log.Printf("Center = %v\n\n", sph.FormatMatrixPrint(centerVec))
+	coordinates := p.imageCoordinates()
 	for index, imageData := range encodedImages {
-		imageName := imageData.Name
-		C := centers[imageName]
-		var vector mat.VecDense
-
-		vector.SubVec(C, centerVec)
-		long, lat := sph.GetLongLat(vector)
-		imageData.Coordinates = sph.Coordinates{
-			Longitude: sph.Rad2Degrees(long),
-			Latitude:  sph.Rad2Degrees(lat)}
+		imageData.Coordinates = coordinates[imageData.Name]
 		encodedImages[index] = imageData
 	}
 
-	camViewer := CameraViewer{Images: encodedImages, Thumbnails: thumbnails, Size: Size{Width: a.Project.Intrinsics.Width, Height: a.Project.Intrinsics.Height}}
-	return &camViewer
+	camViewer := CameraViewer{Images: encodedImages, Thumbnails: thumbnails, Size: Size{Width: p.Intrinsics.Width, Height: p.Intrinsics.Height}}
+	return &camViewer, nil
 }
 
-func (a *App) CreateLandmarksCSV(landmarks []LandmarkCSV) string {
+func (a *App) CreateLandmarksCSV(projectFile string, landmarks []LandmarkCSV) error {
 	log.Println("Create CSV")
+	p, release, err := a.acquire(projectFile)
+	if err != nil {
+		return newError(ERROR_PROJECT, err)
+	}
+	metadata, protocol := p.metadata(), p.protocol()
+	release()
+
 	path, err := runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
-		DefaultDirectory: filepath.Dir(a.Path),
+		DefaultDirectory: filepath.Dir(projectFile),
 		DefaultFilename:  fmt.Sprintf("landmarks_%s.csv", time.Now().Format("20060102_150405")),
 		Filters: []runtime.FileFilter{{
 			DisplayName: "CSV (.csv)",
@@ -356,46 +418,99 @@ func (a *App) CreateLandmarksCSV(landmarks []LandmarkCSV) string {
 		}},
 	})
 	if err != nil {
-		log.Println(err)
-		return err.Error()
+		return newError(ERROR_FILE, err)
+	}
+	if path == "" {
+		return nil
 	}
 
 	f, err := os.Create(path)
 	if err != nil {
-		log.Println(err)
-		return err.Error()
+		return newError(ERROR_FILE, err)
+	}
+	defer f.Close()
+
+	// the specimen metadata are repeated on every row so each row can be ingested on its own
+	var terms []Term
+	if metadata != nil {
+		terms = metadata.Terms()
+	}
+	header := []string{"Label", "Color", "X", "Y", "Z", "X_adjused", "Y_adjusted", "Z_adjusted"}
+	values := make([]string, 0, len(terms))
+	for _, term := range terms {
+		header = append(header, term.Name)
+		values = append(values, term.Value)
+	}
+
+	// rows follow the order of the protocol, the landmarks outside of it come last.
+	// As in the other exports, the landmarks of the protocol not placed are listed with missing coordinates
+	if protocol != nil {
+		placed := make(map[string]bool)
+		for _, landmark := range landmarks {
+			placed[landmark.Label] = true
+		}
+		for _, expected := range protocol.Landmarks {
+			if !placed[expected.Name] {
+				landmarks = append(landmarks, LandmarkCSV{Label: expected.Name, Color: expected.Color})
+			}
+		}
+		rank := make(map[string]int)
+		for index, name := range protocol.names() {
+			rank[name] = index + 1
+		}
+		position := func(label string) int {
+			if index, ok := rank[label]; ok {
+				return index
+			}
+			return len(rank) + 1
+		}
+		sort.SliceStable(landmarks, func(i, j int) bool {
+			return position(landmarks[i].Label) < position(landmarks[j].Label)
+		})
 	}
 
 	writer := csv.NewWriter(f)
-	err = writer.Write([]string{"Label", "Color", "X", "Y", "Z", "X_adjused", "Y_adjusted", "Z_adjusted"})
+	err = writer.Write(header)
 	if err != nil {
-		log.Println(err)
-		return err.Error()
+		return newError(ERROR_FILE, err)
 	}
 	for _, landmark := range landmarks {
-		err = writer.Write([]string{landmark.Label, landmark.Color, landmark.X, landmark.Y, landmark.Z, landmark.XAdjusted, landmark.YAdjusted, landmark.ZAdjusted})
+		row := []string{landmark.Label, landmark.Color, landmark.X, landmark.Y, landmark.Z, landmark.XAdjusted, landmark.YAdjusted, landmark.ZAdjusted}
+		for index := 2; index < len(row); index++ {
+			if row[index] == "" {
+				row[index] = MISSING_TEXT
+			}
+		}
+		err = writer.Write(append(row, values...))
 		if err != nil {
-			log.Println(err)
-			return err.Error()
+			return newError(ERROR_FILE, err)
 		}
 	}
 	writer.Flush()
 	if err := writer.Error(); err != nil {
-		log.Println(err)
-		return err.Error()
+		return newError(ERROR_FILE, err)
 	}
-	return ""
+	return nil
 }
 
-func (a *App) CreateLandmarksJSON(landmarks ExportJSON) string {
+func (a *App) CreateLandmarksJSON(projectFile string, landmarks ExportJSON) error {
 	log.Println("Create JSON")
+	p, release, err := a.acquire(projectFile)
+	if err != nil {
+		return newError(ERROR_PROJECT, err)
+	}
+	landmarks.Metadata = p.metadata()
+	landmarks.Protocol = p.protocol()
+	release()
+
+	landmarks.Measures = computeMeasures(landmarks)
+	landmarks.Curves = computeCurves(landmarks)
 	data, err := json.MarshalIndent(landmarks, "", "  ")
 	if err != nil {
-		log.Println(err)
-		return err.Error()
+		return newError(ERROR_FILE, err)
 	}
 	path, err := runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
-		DefaultDirectory: filepath.Dir(a.Path),
+		DefaultDirectory: filepath.Dir(projectFile),
 		DefaultFilename:  fmt.Sprintf("landmarks_%s.json", time.Now().Format("20060102_150405")),
 		Filters: []runtime.FileFilter{{
 			DisplayName: ".json",
@@ -403,81 +518,99 @@ func (a *App) CreateLandmarksJSON(landmarks ExportJSON) string {
 		}},
 	})
 	if err != nil {
-		log.Println(err)
-		return err.Error()
+		return newError(ERROR_FILE, err)
+	}
+	if path == "" {
+		return nil
 	}
 	err = os.WriteFile(path, data, 0644)
 	if err != nil {
-		log.Println(err)
-		return err.Error()
+		return newError(ERROR_FILE, err)
 	}
 
-	return ""
+	return nil
 }
 
-func (a *App) loadProjectFile(projectFile string) error {
-	// Open our jsonFile
-	jsonFile, err := os.Open(projectFile)
-	// if we os.Open returns an error then handle it
+func readProjectFile(projectFile string) (*project, error) {
+	// Read the project file, or the project inside the archive
+	byteValue, err := readProjectData(projectFile)
 	if err != nil {
-		log.Println(err)
-		return err
+		return nil, err
 	}
-	defer jsonFile.Close()
-
-	byteValue, _ := io.ReadAll(jsonFile)
 
 	var calibFile project
 	err = json.Unmarshal([]byte(byteValue), &calibFile)
 	if err != nil {
-		log.Println(err)
-		return err
+		return nil, err
 	}
+	return &calibFile, nil
+}
 
-	a.Path = projectFile
-	a.Project = &calibFile
-	return nil
+func saveProjectFile(projectFile string, calibFile *project) error {
+	data, err := json.MarshalIndent(calibFile, "", "  ")
+	if err != nil {
+		return err
+	}
+	if isArchive(projectFile) {
+		return updateArchive(projectFile, map[string][]byte{ARCHIVE_PROJECT: data})
+	}
+	return os.WriteFile(projectFile, data, 0644)
 }
 
-func (a *App) ImportNewFile() string {
+func (a *App) ImportNewFile() (string, error) {
 	projectFile := a.openFileDialog("Select Project File", []runtime.FileFilter{
 		{
 			DisplayName: "Sphaeroptica File",
-			Pattern:     "*.sph",
+			Pattern:     "*.sph;*.sphz",
 		},
 	},
 	)
-	err := a.loadProjectFile(projectFile)
+	if projectFile == "" {
+		return "", nil
+	}
+	_, err := a.projects.open(projectFile)
 	if err != nil {
-		log.Println(err.Error())
-		return ""
+		return "", newError(ERROR_PROJECT, err)
 	}
 
-	return projectFile
+	return projectFile, nil
+}
+
+// defaultDirectory returns the directory the dialogs open in, the mutex isn't held while they are open
+func (a *App) defaultDirectory() string {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.DefaultDirectory
+}
+
+func (a *App) setDefaultDirectory(dir string) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.DefaultDirectory = dir
 }
 
 func (a *App) openFileDialog(title string, filters []runtime.FileFilter) string {
 	str, err := runtime.OpenFileDialog(a.ctx, runtime.OpenDialogOptions{
-		DefaultDirectory: a.DefaultDirectory,
+		DefaultDirectory: a.defaultDirectory(),
 		Title:            title,
 		Filters:          filters,
 	})
-	a.DefaultDirectory = filepath.Dir(str)
-	if err != nil {
+	if err != nil || str == "" {
 		return ""
 	}
+	a.setDefaultDirectory(filepath.Dir(str))
 	return str
 }
 
 func (a *App) openDirectoryDialog(title string, filters []runtime.FileFilter) string {
 	str, err := runtime.OpenDirectoryDialog(a.ctx, runtime.OpenDialogOptions{
-		DefaultDirectory: a.DefaultDirectory,
+		DefaultDirectory: a.defaultDirectory(),
 		Title:            title,
 		Filters:          filters,
 	})
-	a.DefaultDirectory = str
-	if err != nil {
+	if err != nil || str == "" {
 		return ""
 	}
+	a.setDefaultDirectory(str)
 	return str
 }
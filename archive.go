@@ -0,0 +1,403 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// Sphaeroptica archives (.sphz) are zip files mirroring a project directory :
+//
+//	project.sph               the project (calibration included)
+//	protocol/<image>          the reference images of the protocol
+//	<Thumbnails>/<image>      the thumbnails
+//	<image>                   the full images (optional)
+//	sessions/<name>.json      the landmark sessions (sessions/<project>/<name>.json next to a project file)
+const ARCHIVE_EXT = ".sphz"
+const ARCHIVE_PROJECT = "project.sph"
+
+// Open archives are shared between the bindings and the FileLoader
+// so images can be served without extracting the archive.
+// The reader of an archive is only used under its read lock, it is closed
+// and the archive changed under its write lock once the reads in progress are done
+type openArchive struct {
+	mu sync.RWMutex
+	// nil until the archive is read, and once it is closed
+	reader *zip.ReadCloser
+}
+
+type archiveCache struct {
+	mu       sync.Mutex
+	archives map[string]*openArchive
+}
+
+var archives = &archiveCache{archives: make(map[string]*openArchive)}
+
+// get returns the archive, by the same key as the projects
+func (c *archiveCache) get(archivePath string) *openArchive {
+	key := projectKey(archivePath)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	archive, ok := c.archives[key]
+	if !ok {
+		archive = &openArchive{}
+		c.archives[key] = archive
+	}
+	return archive
+}
+
+// use calls read with the archive, opened if needed, it isn't changed or closed until read returns
+func (c *archiveCache) use(archivePath string, read func(*zip.ReadCloser) error) error {
+	archive := c.get(archivePath)
+	archive.mu.RLock()
+	// it is opened under the write lock, and can be closed again before the read lock is taken back
+	for archive.reader == nil {
+		archive.mu.RUnlock()
+		archive.mu.Lock()
+		if archive.reader == nil {
+			reader, err := zip.OpenReader(archivePath)
+			if err != nil {
+				archive.mu.Unlock()
+				return err
+			}
+			archive.reader = reader
+		}
+		archive.mu.Unlock()
+		archive.mu.RLock()
+	}
+	defer archive.mu.RUnlock()
+	return read(archive.reader)
+}
+
+// has reports if the archive is open, used by someone else
+func (c *archiveCache) has(archivePath string) bool {
+	archive := c.get(archivePath)
+	archive.mu.RLock()
+	defer archive.mu.RUnlock()
+	return archive.reader != nil
+}
+
+// update closes the archive once the reads in progress are done and calls change,
+// the archive can't be read until change returns
+func (c *archiveCache) update(archivePath string, change func() error) error {
+	archive := c.get(archivePath)
+	archive.mu.Lock()
+	defer archive.mu.Unlock()
+
+	if archive.reader != nil {
+		archive.reader.Close()
+		archive.reader = nil
+	}
+	return change()
+}
+
+func (c *archiveCache) close(archivePath string) {
+	c.update(archivePath, func() error { return nil })
+}
+
+func isArchive(projectFile string) bool {
+	return strings.EqualFold(filepath.Ext(projectFile), ARCHIVE_EXT)
+}
+
+// splitArchivePath splits "/dir/specimen.sphz/thumbnails/img.jpg" into
+// the archive path and the name of the entry inside the archive
+func splitArchivePath(requested string) (string, string, bool) {
+	// compared on the path itself, lowering it can change the length of the other characters
+	separator := ARCHIVE_EXT + "/"
+	for index := 0; index+len(separator) <= len(requested); index++ {
+		if strings.EqualFold(requested[index:index+len(separator)], separator) {
+			archiveEnd := index + len(ARCHIVE_EXT)
+			return requested[:archiveEnd], requested[archiveEnd+1:], true
+		}
+	}
+	return "", "", false
+}
+
+// readFile reads a file on disk or an entry of an archive
+func readFile(requested string) ([]byte, error) {
+	archivePath, name, ok := splitArchivePath(requested)
+	if !ok {
+		return os.ReadFile(requested)
+	}
+	var data []byte
+	err := archives.use(archivePath, func(reader *zip.ReadCloser) error {
+		var err error
+		data, err = fs.ReadFile(reader, name)
+		return err
+	})
+	return data, err
+}
+
+// useProjectFS calls read with the files of a project, either its directory or its archive
+func useProjectFS(projectFile string, read func(fs.FS) error) error {
+	if isArchive(projectFile) {
+		return archives.use(projectFile, func(reader *zip.ReadCloser) error {
+			return read(reader)
+		})
+	}
+	return read(os.DirFS(filepath.Dir(projectFile)))
+}
+
+// projectRoot returns the absolute path the project files are served from
+func projectRoot(projectFile string) string {
+	if isArchive(projectFile) {
+		archivePath, _ := filepath.Abs(projectFile)
+		return archivePath
+	}
+	projectDirAbs, _ := filepath.Abs(filepath.Dir(projectFile))
+	return projectDirAbs
+}
+
+func readProjectData(projectFile string) ([]byte, error) {
+	if !isArchive(projectFile) {
+		return os.ReadFile(projectFile)
+	}
+	var data []byte
+	err := useProjectFS(projectFile, func(files fs.FS) error {
+		var err error
+		data, err = fs.ReadFile(files, ARCHIVE_PROJECT)
+		return err
+	})
+	return data, err
+}
+
+// updateArchive replaces (or adds) the given entries of an archive
+func updateArchive(archivePath string, entries map[string][]byte) error {
+	return archives.update(archivePath, func() error {
+		return rewriteArchive(archivePath, entries)
+	})
+}
+
+// rewriteArchive rewrites the whole archive, the entries not replaced are copied without being recompressed
+func rewriteArchive(archivePath string, entries map[string][]byte) error {
+	reader, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(archivePath), ".sphz-*")
+	if err != nil {
+		reader.Close()
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	writer := zip.NewWriter(tmp)
+	err = func() error {
+		for _, file := range reader.File {
+			if _, ok := entries[file.Name]; ok {
+				continue
+			}
+			if err := writer.Copy(file); err != nil {
+				return err
+			}
+		}
+		if err := writeEntries(writer, entries); err != nil {
+			return err
+		}
+		return writer.Close()
+	}()
+	// the archive can't be replaced while it is open on Windows
+	reader.Close()
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), archivePath)
+}
+
+func writeEntries(writer *zip.Writer, entries map[string][]byte) error {
+	names := make([]string, 0, len(entries))
+	for name := range entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		w, err := writer.Create(name)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(entries[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Export the project to a single archive
+func (a *App) ExportArchive(projectFile string, fullImages bool) (string, error) {
+	path, err := runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
+		DefaultDirectory: filepath.Dir(projectFile),
+		DefaultFilename:  strings.TrimSuffix(filepath.Base(projectFile), filepath.Ext(projectFile)) + ARCHIVE_EXT,
+		Filters: []runtime.FileFilter{{
+			DisplayName: "Sphaeroptica Archive (*.sphz)",
+			Pattern:     "*.sphz",
+		}},
+	})
+	if err != nil {
+		return "", newError(ERROR_FILE, err)
+	}
+	if path == "" {
+		return "", nil
+	}
+	if projectKey(path) == projectKey(projectFile) {
+		return "", errorf(ERROR_INVALID_ARGUMENT, "cannot overwrite the archive being exported")
+	}
+
+	// the project is copied under its lock, the archive is written without blocking the other calls
+	p, release, err := a.acquire(projectFile)
+	if err != nil {
+		return "", newError(ERROR_PROJECT, err)
+	}
+	// the reference images of the protocol are packed along with it
+	archived := *p
+	referenceFiles := map[string]string{}
+	if p.Protocol != nil {
+		archived.Protocol, referenceFiles = p.Protocol.archived(projectFile)
+	}
+	data, err := json.MarshalIndent(&archived, "", "  ")
+	images := make([]string, 0, len(p.Extrinsics))
+	for image := range p.Extrinsics {
+		images = append(images, image)
+	}
+	thumbnails := p.Thumbnails
+	release()
+	if err != nil {
+		return "", newError(ERROR_PROJECT, err)
+	}
+
+	references := make(map[string][]byte, len(referenceFiles))
+	for entry, file := range referenceFiles {
+		reference, err := readFile(file)
+		if err != nil {
+			log.Printf("Missing file %s, skipped\n", file)
+			continue
+		}
+		references[entry] = reference
+	}
+
+	err = writeArchive(path, projectFile, data, references, images, thumbnails, fullImages)
+	if err != nil {
+		os.Remove(path)
+		return "", newError(ERROR_FILE, err)
+	}
+	return path, nil
+}
+
+// writeArchive writes the project data, the reference images of its protocol,
+// the images (thumbnails, and the full images if asked) and the sessions
+func writeArchive(archivePath string, projectFile string, data []byte, references map[string][]byte, images []string, thumbnails string, fullImages bool) error {
+	return useProjectFS(projectFile, func(files fs.FS) error {
+		return writeArchiveFiles(archivePath, files, projectFile, data, references, images, thumbnails, fullImages)
+	})
+}
+
+func writeArchiveFiles(archivePath string, files fs.FS, projectFile string, data []byte, references map[string][]byte, images []string, thumbnails string, fullImages bool) error {
+	f, err := os.Create(archivePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	writer := zip.NewWriter(f)
+
+	w, err := writer.Create(ARCHIVE_PROJECT)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(references))
+	for name := range references {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		w, err := writer.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store})
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(references[name]); err != nil {
+			return err
+		}
+	}
+
+	sort.Strings(images)
+	// entries of the archive by the project file they are copied from
+	entries := make([][2]string, 0)
+	for _, image := range images {
+		if thumbnails != "" {
+			thumbnail := path.Join(thumbnails, image)
+			entries = append(entries, [2]string{thumbnail, thumbnail})
+		}
+		if fullImages {
+			entries = append(entries, [2]string{image, image})
+		}
+	}
+
+	// the sessions of the project are at the root of its archive
+	sessions, _ := sessionEntries(files, projectFile)
+	for _, session := range sessions {
+		entries = append(entries, [2]string{path.Join(SESSIONS_DIR, path.Base(session)), session})
+	}
+
+	for _, entry := range entries {
+		// images are already compressed, store them to keep random access cheap
+		method := zip.Store
+		if path.Ext(entry[0]) == ".json" {
+			method = zip.Deflate
+		}
+		if err := copyToArchive(writer, files, entry[0], entry[1], method); err != nil {
+			return fmt.Errorf("%s : %w", entry[1], err)
+		}
+	}
+
+	return writer.Close()
+}
+
+// copyToArchive copies the project file source to the entry name of the archive
+func copyToArchive(writer *zip.Writer, files fs.FS, name string, source string, method uint16) error {
+	src, err := files.Open(source)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			log.Printf("Missing file %s, skipped\n", source)
+			return nil
+		}
+		return err
+	}
+	defer src.Close()
+
+	w, err := writer.CreateHeader(&zip.FileHeader{Name: name, Method: method})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, src)
+	return err
+}
+
+// archiveHas reports if the entry exists in the project archive
+func archiveHas(projectFile string, name string) bool {
+	err := useProjectFS(projectFile, func(files fs.FS) error {
+		_, err := fs.Stat(files, name)
+		return err
+	})
+	return err == nil
+}
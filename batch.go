@@ -0,0 +1,358 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// Merged exports of the landmark sessions of every project of a directory
+
+var BATCH_EXPORTS = map[string]ExportFile{
+	"CSV":  {Label: "CSV table (.csv)", Extension: ".csv"},
+	"TPS":  {Label: "TPS (.tps)", Extension: ".tps"},
+	"JSON": {Label: "JSON (.json)", Extension: ".json"},
+}
+
+var BATCH_WRITER = map[string]func(io.Writer, []string, []batchEntry) error{
+	"CSV":  writeBatchCSV,
+	"TPS":  writeBatchTPS,
+	"JSON": writeBatchJSON,
+}
+
+type batchEntry struct {
+	Report    BatchSpecimen
+	Landmarks ExportJSON
+	Metadata  Metadata
+}
+
+// latestSession returns the last saved landmark session of the project
+func latestSession(projectFile string) (string, ExportJSON, error) {
+	sessions, err := readSessions(projectFile)
+	if err != nil {
+		return "", ExportJSON{}, err
+	}
+	if len(sessions) == 0 {
+		return "", ExportJSON{}, fmt.Errorf("no landmark session")
+	}
+
+	latest := ""
+	err = useProjectFS(projectFile, func(files fs.FS) error {
+		var latestTime time.Time
+		for name := range sessions {
+			info, err := fs.Stat(files, path.Join(sessionsDir(projectFile), name+".json"))
+			if err != nil {
+				continue
+			}
+			if latest == "" || info.ModTime().After(latestTime) || (info.ModTime().Equal(latestTime) && name > latest) {
+				latest = name
+				latestTime = info.ModTime()
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", ExportJSON{}, err
+	}
+	return latest, sessions[latest], nil
+}
+
+// collectSpecimens reads the last session of every project found in the directory
+func collectSpecimens(dir string) ([]batchEntry, []string, error) {
+	entries := make([]batchEntry, 0)
+	skipped := make([]string, 0)
+	specimens := make(map[string]bool)
+
+	err := filepath.WalkDir(dir, func(projectFile string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		ext := strings.ToLower(filepath.Ext(projectFile))
+		if d.IsDir() || (ext != ".sph" && ext != ARCHIVE_EXT) {
+			return nil
+		}
+		// the archives read are closed once done, a folder can hold many of them
+		if isArchive(projectFile) && !archives.has(projectFile) {
+			defer archives.close(projectFile)
+		}
+
+		calibFile, err := readProjectFile(projectFile)
+		if err != nil {
+			log.Printf("%s : %v\n", projectFile, err)
+			skipped = append(skipped, projectFile)
+			return nil
+		}
+		session, landmarks, err := latestSession(projectFile)
+		if err != nil {
+			log.Printf("%s : %v\n", projectFile, err)
+			skipped = append(skipped, projectFile)
+			return nil
+		}
+
+		specimen := specimenID(&calibFile.Metadata, projectFile)
+		for index := 2; specimens[specimen]; index++ {
+			specimen = fmt.Sprintf("%s_%d", specimenID(&calibFile.Metadata, projectFile), index)
+		}
+		specimens[specimen] = true
+
+		report := BatchSpecimen{Specimen: specimen, Project: projectFile, Session: session}
+		if calibFile.Protocol != nil {
+			landmarks.Protocol = calibFile.Protocol
+			report.Protocol = calibFile.Protocol.Name
+		}
+		entries = append(entries, batchEntry{
+			Report:    report,
+			Landmarks: landmarks,
+			Metadata:  calibFile.Metadata,
+		})
+		return nil
+	})
+	return entries, skipped, err
+}
+
+// batchTemplate returns the landmarks of the reference specimen, the first one with a protocol
+// (the one with the most landmarks if none has), with the specimens following the same template.
+// The other specimens are flagged with the reason of the mismatch, the landmarks missing
+// (or not triangulated) are reported for every specimen
+func batchTemplate(entries []batchEntry) ([]string, []batchEntry) {
+	if len(entries) == 0 {
+		return []string{}, entries
+	}
+	reference := entries[0]
+	for _, entry := range entries[1:] {
+		if reference.Landmarks.Protocol != nil {
+			break
+		}
+		if entry.Landmarks.Protocol != nil || len(entry.Landmarks.Landmarks) > len(reference.Landmarks.Landmarks) {
+			reference = entry
+		}
+	}
+
+	template := make([]string, 0)
+	known := make(map[string]bool)
+	for _, landmark := range orderedLandmarks(reference.Landmarks) {
+		template = append(template, landmark.Label)
+		known[landmark.Label] = true
+	}
+
+	matching := make([]batchEntry, 0, len(entries))
+	for index, entry := range entries {
+		report := &entries[index].Report
+		report.Missing = make([]string, 0)
+		if mismatch := templateMismatch(reference, entry, known); mismatch != "" {
+			report.Mismatch = mismatch
+			continue
+		}
+		positions := exportedPositions(entry.Landmarks)
+		for _, label := range template {
+			if _, ok := positions[label]; !ok {
+				report.Missing = append(report.Missing, label)
+			}
+		}
+		matching = append(matching, entries[index])
+	}
+	return template, matching
+}
+
+// templateMismatch returns why the specimen doesn't follow the template of the reference, empty if it does
+func templateMismatch(reference batchEntry, entry batchEntry, known map[string]bool) string {
+	protocol, referenceProtocol := entry.Landmarks.Protocol, reference.Landmarks.Protocol
+	if protocol != nil && referenceProtocol != nil && !sameTemplate(protocol, referenceProtocol) {
+		return fmt.Sprintf("protocol %s differs from protocol %s of %s", protocol.Name, referenceProtocol.Name, reference.Report.Specimen)
+	}
+	unknown := make([]string, 0)
+	for _, landmark := range orderedLandmarks(entry.Landmarks) {
+		if !known[landmark.Label] {
+			unknown = append(unknown, landmark.Label)
+		}
+	}
+	if len(unknown) > 0 {
+		return fmt.Sprintf("landmarks %s aren't in the template of %s", strings.Join(unknown, ", "), reference.Report.Specimen)
+	}
+	return ""
+}
+
+// sameTemplate reports if the protocols have the same landmarks and curves, in the same order
+func sameTemplate(protocol *Protocol, other *Protocol) bool {
+	if !slices.Equal(protocol.names(), other.names()) || len(protocol.Curves) != len(other.Curves) {
+		return false
+	}
+	for index, curve := range protocol.Curves {
+		otherCurve := other.Curves[index]
+		if curve.Name != otherCurve.Name || curve.Start != otherCurve.Start || curve.End != otherCurve.End || curve.Semilandmarks != otherCurve.Semilandmarks {
+			return false
+		}
+	}
+	return true
+}
+
+// alignedLandmarks returns the landmarks of the specimen in the order of the template
+func alignedLandmarks(template []string, landmarks ExportJSON) []LandmarkJSON {
+	byLabel := make(map[string]LandmarkJSON)
+	for _, landmark := range orderedLandmarks(landmarks) {
+		byLabel[landmark.Label] = landmark
+	}
+	aligned := make([]LandmarkJSON, len(template))
+	for index, label := range template {
+		landmark, ok := byLabel[label]
+		if !ok {
+			landmark = LandmarkJSON{Label: label}
+		}
+		aligned[index] = landmark
+	}
+	return aligned
+}
+
+func writeBatchCSV(w io.Writer, template []string, entries []batchEntry) error {
+	writer := csv.NewWriter(w)
+	header := []string{"Specimen", "Session", "Label", "X", "Y", "Z"}
+	for _, term := range (Metadata{}).Terms() {
+		header = append(header, term.Name)
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		values := make([]string, 0)
+		for _, term := range entry.Metadata.Terms() {
+			values = append(values, term.Value)
+		}
+		for _, landmark := range alignedLandmarks(template, entry.Landmarks) {
+			row := []string{entry.Report.Specimen, entry.Report.Session, landmark.Label, MISSING_TEXT, MISSING_TEXT, MISSING_TEXT}
+			if len(landmark.Position) >= 3 {
+				row[3], row[4], row[5] = formatCoordinate(landmark.Position[0]), formatCoordinate(landmark.Position[1]), formatCoordinate(landmark.Position[2])
+			}
+			if err := writer.Write(append(row, values...)); err != nil {
+				return err
+			}
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// writeBatchTPS writes the landmarks of the template followed by the semilandmarks of every curve found,
+// aligned by label so every specimen has the same number of points
+func writeBatchTPS(w io.Writer, template []string, entries []batchEntry) error {
+	semilandmarksTemplate := make([]string, 0)
+	known := make(map[string]bool)
+	for _, entry := range entries {
+		for _, point := range semilandmarks(entry.Landmarks) {
+			if !known[point.Label] {
+				known[point.Label] = true
+				semilandmarksTemplate = append(semilandmarksTemplate, point.Label)
+			}
+		}
+	}
+
+	for _, entry := range entries {
+		points := alignedLandmarks(template, entry.Landmarks)
+		bySemilandmark := make(map[string]LandmarkJSON)
+		for _, point := range semilandmarks(entry.Landmarks) {
+			bySemilandmark[point.Label] = point
+		}
+		for _, label := range semilandmarksTemplate {
+			point, ok := bySemilandmark[label]
+			if !ok {
+				point = LandmarkJSON{Label: label}
+			}
+			points = append(points, point)
+		}
+		err := writeTPS(w, points, entry.Landmarks.ScaleFactor, entry.Report.Specimen)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeBatchJSON(w io.Writer, template []string, entries []batchEntry) error {
+	specimens := make(map[string]ExportJSON)
+	for _, entry := range entries {
+		landmarks := entry.Landmarks
+		metadata := entry.Metadata
+		landmarks.Metadata = &metadata
+		landmarks.Measures = computeMeasures(landmarks)
+		landmarks.Curves = computeCurves(landmarks)
+		specimens[entry.Report.Specimen] = landmarks
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(struct {
+		Template  []string              `json:"template"`
+		Specimens map[string]ExportJSON `json:"specimens"`
+	}{Template: template, Specimens: specimens})
+}
+
+// saveBatch asks where to save the merged file and writes it, returns "" if it was cancelled
+func (a *App) saveBatch(dir string, format string, template []string, entries []batchEntry) (string, error) {
+	file := BATCH_EXPORTS[format]
+	output, err := runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
+		DefaultDirectory: dir,
+		DefaultFilename:  fmt.Sprintf("landmarks_%s%s", time.Now().Format("20060102_150405"), file.Extension),
+		Filters: []runtime.FileFilter{{
+			DisplayName: file.Label,
+			Pattern:     "*" + file.Extension,
+		}},
+	})
+	if err != nil || output == "" {
+		return "", err
+	}
+
+	f, err := os.Create(output)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if err := BATCH_WRITER[format](f, template, entries); err != nil {
+		return "", err
+	}
+	return output, nil
+}
+
+// Merge the last landmark session of every project of a directory into a single file
+func (a *App) BatchExportLandmarks(format string) (BatchReport, error) {
+	log.Printf("Batch export landmarks to %s\n", format)
+	if _, ok := BATCH_EXPORTS[format]; !ok {
+		return BatchReport{}, errorf(ERROR_INVALID_ARGUMENT, "unknown batch format %s", format)
+	}
+
+	dir := a.openDirectoryDialog("Select Projects Folder", []runtime.FileFilter{})
+	if dir == "" {
+		return BatchReport{}, nil
+	}
+	entries, skipped, err := collectSpecimens(dir)
+	if err != nil {
+		return BatchReport{}, newError(ERROR_FILE, err)
+	}
+	template, matching := batchTemplate(entries)
+
+	// the specimens not following the template are reported, but left out of the file
+	report := BatchReport{Template: template, Specimens: make([]BatchSpecimen, len(entries)), Skipped: skipped}
+	for index, entry := range entries {
+		report.Specimens[index] = entry.Report
+	}
+	if len(matching) == 0 {
+		return report, nil
+	}
+
+	output, err := a.saveBatch(dir, format, template, matching)
+	if err != nil {
+		return report, newError(ERROR_FILE, err)
+	}
+	report.Output = output
+	return report, nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"gonum.org/v1/gonum/mat"
+)
+
+// Matrices of the cameras, parsed once per frame of the project instead of at every reprojection
+
+type cameraMatrices struct {
+	intrinsics *mat.Dense
+	distCoeffs *mat.Dense
+	// extrinsics and projection matrices (intrinsics * extrinsics) in the frame of the project
+	extrinsics  map[string]*mat.Dense
+	projections map[string]*mat.Dense
+}
+
+// matrices returns the matrices of the cameras, built again after a change of frame
+func (p *project) matrices() *cameraMatrices {
+	if p.cache != nil {
+		return p.cache
+	}
+	cache := cameraMatrices{
+		intrinsics:  mat.NewDense(p.Intrinsics.CameraMatrix.Shape.Row, p.Intrinsics.CameraMatrix.Shape.Col, p.Intrinsics.CameraMatrix.Data),
+		distCoeffs:  mat.NewDense(p.Intrinsics.DistortionMatrix.Shape.Row, p.Intrinsics.DistortionMatrix.Shape.Col, p.Intrinsics.DistortionMatrix.Data),
+		extrinsics:  make(map[string]*mat.Dense, len(p.Extrinsics)),
+		projections: make(map[string]*mat.Dense, len(p.Extrinsics)),
+	}
+	for image := range p.Extrinsics {
+		extrinsics := p.extrinsics(image)
+		var projection mat.Dense
+		projection.Mul(cache.intrinsics, extrinsics.Slice(0, 3, 0, 4))
+		cache.extrinsics[image] = extrinsics
+		cache.projections[image] = &projection
+	}
+	p.cache = &cache
+	return p.cache
+}
+
+// invalidate drops what is computed from the frame of the project, to call after it changes
+func (p *project) invalidate() {
+	p.index = nil
+	p.cache = nil
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+
+	"gonum.org/v1/gonum/mat"
+	sph "sphaeroptica.be/photogrammetry/photogrammetry"
+)
+
+// Curves of sliding semilandmarks, exported after the landmarks
+
+// semilandmarksCount returns the number of semilandmarks of the curve, the protocol prevails
+func semilandmarksCount(landmarks ExportJSON, curve CurveJSON) int {
+	if landmarks.Protocol != nil {
+		for _, expected := range landmarks.Protocol.Curves {
+			if expected.Name == curve.Label {
+				return expected.Semilandmarks
+			}
+		}
+	}
+	return curve.Semilandmarks
+}
+
+// computeCurve resamples the curve from the triangulated landmarks,
+// the landmarks not triangulated are listed as missing
+func computeCurve(landmarks ExportJSON, curve CurveJSON) (CurveJSON, error) {
+	curve.Semilandmarks = semilandmarksCount(landmarks, curve)
+	curve.Points = [][]float64{}
+	curve.Missing = []string{}
+	if len(curve.Landmarks) < 2 || curve.Semilandmarks < 1 {
+		return curve, fmt.Errorf("curve %s : 2 landmarks and 1 semilandmark are needed at least", curve.Label)
+	}
+
+	points := make([]mat.Vector, 0, len(curve.Landmarks))
+	for _, reference := range curve.Landmarks {
+		landmark, ok := findLandmark(landmarks, reference)
+		if !ok || len(landmark.Position) < 3 {
+			curve.Missing = append(curve.Missing, reference)
+			continue
+		}
+		points = append(points, mat.NewVecDense(len(landmark.Position), landmark.Position))
+	}
+	if len(curve.Missing) != 0 {
+		return curve, nil
+	}
+
+	semilandmarks, err := sph.Semilandmarks(points, curve.Semilandmarks)
+	if err != nil {
+		return curve, fmt.Errorf("curve %s : %w", curve.Label, err)
+	}
+	for _, point := range semilandmarks {
+		curve.Points = append(curve.Points, point.RawVector().Data)
+	}
+	return curve, nil
+}
+
+// computeCurves resamples the curves again from the current positions of the landmarks
+func computeCurves(landmarks ExportJSON) []CurveJSON {
+	curves := make([]CurveJSON, len(landmarks.Curves))
+	for index, curve := range landmarks.Curves {
+		computed, err := computeCurve(landmarks, curve)
+		if err != nil {
+			log.Println(err)
+		}
+		curves[index] = computed
+	}
+	return curves
+}
+
+func semilandmarkLabel(curve CurveJSON, index int) string {
+	return fmt.Sprintf("%s_%d", curve.Label, index+1)
+}
+
+// curvePoints returns the labels of the landmarks clicked along the curves between their ends
+func curvePoints(landmarks ExportJSON) map[string]bool {
+	ends := make(map[string]bool)
+	points := make(map[string]bool)
+	for _, curve := range landmarks.Curves {
+		for index, reference := range curve.Landmarks {
+			landmark, ok := findLandmark(landmarks, reference)
+			if !ok {
+				continue
+			}
+			if index == 0 || index == len(curve.Landmarks)-1 {
+				ends[landmark.Label] = true
+			} else {
+				points[landmark.Label] = true
+			}
+		}
+	}
+	// the end of a curve is a landmark even if another curve goes through it
+	for label := range ends {
+		delete(points, label)
+	}
+	return points
+}
+
+// semilandmarks returns the semilandmarks of every curve as landmarks,
+// missing if the curve can't be computed
+func semilandmarks(landmarks ExportJSON) []LandmarkJSON {
+	points := make([]LandmarkJSON, 0)
+	for _, curve := range computeCurves(landmarks) {
+		color := DEFAULT_LANDMARK_COLOR
+		if len(curve.Landmarks) > 0 {
+			if landmark, ok := findLandmark(landmarks, curve.Landmarks[0]); ok {
+				color = landmark.Color
+			}
+		}
+		for index := 0; index < curve.Semilandmarks; index++ {
+			point := LandmarkJSON{Label: semilandmarkLabel(curve, index), Color: color, Poses: map[string]PoseJSON{}}
+			if index < len(curve.Points) {
+				point.Position = curve.Points[index]
+			}
+			points = append(points, point)
+		}
+	}
+	return points
+}
+
+// exportedPositions returns the triangulated fixed landmarks by label
+func exportedPositions(landmarks ExportJSON) map[string][]float64 {
+	positions := make(map[string][]float64)
+	for _, landmark := range orderedLandmarks(landmarks) {
+		if len(landmark.Position) >= 3 {
+			positions[landmark.Label] = landmark.Position
+		}
+	}
+	return positions
+}
+
+// writeCurveslide writes the curveslide matrix of geomorph (gpagen, digit.curves):
+// one row (before, slide, after) per semilandmark, with the indices of the TPS export
+func writeCurveslide(w io.Writer, export LandmarksExport) error {
+	indices := make(map[string]int)
+	for index, landmark := range tpsLandmarks(export.Landmarks) {
+		indices[landmark.Label] = index + 1
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"before", "slide", "after"}); err != nil {
+		return err
+	}
+	for _, curve := range export.Landmarks.Curves {
+		if len(curve.Landmarks) < 2 {
+			continue
+		}
+		start, okStart := findLandmark(export.Landmarks, curve.Landmarks[0])
+		end, okEnd := findLandmark(export.Landmarks, curve.Landmarks[len(curve.Landmarks)-1])
+		if !okStart || !okEnd {
+			return fmt.Errorf("curve %s : unknown end landmarks", curve.Label)
+		}
+
+		count := semilandmarksCount(export.Landmarks, curve)
+		chain := []int{indices[start.Label]}
+		for index := 0; index < count; index++ {
+			chain = append(chain, indices[semilandmarkLabel(curve, index)])
+		}
+		chain = append(chain, indices[end.Label])
+
+		for index := 1; index < len(chain)-1; index++ {
+			err := writer.Write([]string{strconv.Itoa(chain[index-1]), strconv.Itoa(chain[index]), strconv.Itoa(chain[index+1])})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// Resample the curves from the landmarks, to be called again when a landmark moves
+func (a *App) ComputeCurves(projectFile string, landmarks ExportJSON) ([]CurveJSON, error) {
+	p, release, err := a.acquire(projectFile)
+	if err != nil {
+		return []CurveJSON{}, newError(ERROR_PROJECT, err)
+	}
+	landmarks.Protocol = p.protocol()
+	release()
+	return computeCurves(landmarks), nil
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"gonum.org/v1/gonum/mat"
+	sph "sphaeroptica.be/photogrammetry/photogrammetry"
+)
+
+// distance in pixels between two points of the epipolar curves
+const EPIPOLAR_STEP = 5
+
+// Get the epipolar curve in imageB of a pose placed on imageA, in distorted pixels of imageB,
+// the landmark lies on this curve
+func (a *App) EpipolarCurve(projectFile string, imageA string, pose sph.Pos, imageB string) ([]sph.Pos, error) {
+	p, release, err := a.acquire(projectFile)
+	if err != nil {
+		return []sph.Pos{}, newError(ERROR_PROJECT, err)
+	}
+	defer release()
+	if err := p.checkImages(imageA, imageB); err != nil {
+		return []sph.Pos{}, newError(ERROR_UNKNOWN_IMAGE, err)
+	}
+
+	matrices := p.matrices()
+	return sph.EpipolarCurve(
+		mat.NewVecDense(2, []float64{pose.X, pose.Y}),
+		matrices.intrinsics, matrices.distCoeffs,
+		matrices.extrinsics[imageA], matrices.extrinsics[imageB],
+		float64(p.Intrinsics.Width), float64(p.Intrinsics.Height),
+		EPIPOLAR_STEP,
+	), nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+)
+
+// Errors returned by the bindings, Wails rejects the promise of the call with their message:
+// the JSON object {"code": ..., "message": ...} the frontend can display
+
+const (
+	// the project can't be read, saved or isn't open
+	ERROR_PROJECT = "PROJECT"
+	// the image isn't part of the project
+	ERROR_UNKNOWN_IMAGE = "UNKNOWN_IMAGE"
+	// the arguments of the call are invalid (unknown format, landmark not triangulated...)
+	ERROR_INVALID_ARGUMENT = "INVALID_ARGUMENT"
+	// a file (image, export, import) can't be read or written
+	ERROR_FILE = "FILE"
+	// the computation has no solution with the data given
+	ERROR_COMPUTATION = "COMPUTATION"
+)
+
+type AppError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	err     error
+}
+
+func (e *AppError) Error() string {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return e.Message
+	}
+	return string(data)
+}
+
+func (e *AppError) Unwrap() error {
+	return e.err
+}
+
+// newError wraps the error with the code and logs it, an AppError keeps its own code
+func newError(code string, err error) error {
+	if err == nil {
+		return nil
+	}
+	// already logged when created
+	var appError *AppError
+	if errors.As(err, &appError) {
+		return err
+	}
+	log.Println(err)
+	return &AppError{Code: code, Message: err.Error(), err: err}
+}
+
+// errorf formats and logs a new error with the code
+func errorf(code string, format string, args ...any) error {
+	return newError(code, fmt.Errorf(format, args...))
+}
+
+// checkImages returns an error if one of the images isn't part of the project (ERROR_UNKNOWN_IMAGE)
+func (p *project) checkImages(images ...string) error {
+	for _, image := range images {
+		if _, ok := p.Extrinsics[image]; !ok {
+			return fmt.Errorf("unknown image %s", image)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+	"gonum.org/v1/gonum/mat"
+	imp "sphaeroptica.be/imports/imports"
+	sph "sphaeroptica.be/photogrammetry/photogrammetry"
+)
+
+// Missing landmarks in NTSYS, the value is declared in its header
+const MISSING_VALUE = -999
+
+var EXPORTS_FILES = map[string]ExportFile{
+	"TPS":                {Label: "TPS (geomorph, MorphoJ)", Extension: ".tps"},
+	"SlicerMarkupsRAS":   {Label: "3D Slicer Markups, RAS", Extension: ".mrk.json"},
+	"SlicerMarkupsLPS":   {Label: "3D Slicer Markups, LPS", Extension: ".mrk.json"},
+	"SlicerFCSVRAS":      {Label: "3D Slicer legacy FCSV, RAS", Extension: ".fcsv"},
+	"SlicerFCSVLPS":      {Label: "3D Slicer legacy FCSV, LPS", Extension: ".fcsv"},
+	"Morphologika":       {Label: "Morphologika", Extension: ".txt"},
+	"NTSYS":              {Label: "NTSYS", Extension: ".nts"},
+	"IDAVLandmarkEditor": {Label: "IDAV Landmark Editor", Extension: ".pts"},
+	"PLY":                {Label: "PLY point cloud", Extension: ".ply"},
+	"PLYCameras":         {Label: "PLY point cloud with cameras", Extension: ".ply"},
+	"OBJ":                {Label: "OBJ point cloud", Extension: ".obj"},
+	"OBJCameras":         {Label: "OBJ point cloud with cameras", Extension: ".obj"},
+	"MetashapeMarkers":   {Label: "Metashape markers", Extension: ".xml"},
+	"Measures":           {Label: "Distances and measures table", Extension: ".csv"},
+	"GeomorphCurveslide": {Label: "geomorph curveslide matrix (semilandmarks of the TPS export)", Extension: ".csv"},
+}
+
+var EXPORTS_WRITER = map[string]func(io.Writer, LandmarksExport) error{
+	"TPS": func(w io.Writer, export LandmarksExport) error {
+		return writeTPS(w, tpsLandmarks(export.Landmarks), export.Landmarks.ScaleFactor, export.Specimen)
+	},
+	"SlicerMarkupsRAS": func(w io.Writer, export LandmarksExport) error {
+		return writeSlicerMarkups(w, export.Landmarks, "RAS", export.Project.metric())
+	},
+	"SlicerMarkupsLPS": func(w io.Writer, export LandmarksExport) error {
+		return writeSlicerMarkups(w, export.Landmarks, "LPS", export.Project.metric())
+	},
+	"SlicerFCSVRAS": func(w io.Writer, export LandmarksExport) error {
+		return writeSlicerFCSV(w, export.Landmarks, "RAS")
+	},
+	"SlicerFCSVLPS": func(w io.Writer, export LandmarksExport) error {
+		return writeSlicerFCSV(w, export.Landmarks, "LPS")
+	},
+	"Morphologika":       writeMorphologika,
+	"NTSYS":              writeNTSYS,
+	"IDAVLandmarkEditor": writeIDAV,
+	"PLY": func(w io.Writer, export LandmarksExport) error {
+		return writePLY(w, export, false)
+	},
+	"PLYCameras": func(w io.Writer, export LandmarksExport) error {
+		return writePLY(w, export, true)
+	},
+	"OBJ": func(w io.Writer, export LandmarksExport) error {
+		return writeOBJ(w, export, false)
+	},
+	"OBJCameras": func(w io.Writer, export LandmarksExport) error {
+		return writeOBJ(w, export, true)
+	},
+	"MetashapeMarkers":   writeMetashapeMarkers,
+	"Measures":           writeMeasures,
+	"GeomorphCurveslide": writeCurveslide,
+}
+
+// Files written next to the export, from its path
+var EXPORTS_SIDECAR = map[string]func(string, LandmarksExport) error{
+	"SlicerMarkupsRAS": writeSlicerTransform,
+	"SlicerMarkupsLPS": writeSlicerTransform,
+	"SlicerFCSVRAS":    writeSlicerTransform,
+	"SlicerFCSVLPS":    writeSlicerTransform,
+}
+
+// coordinates returns the coordinates of the landmark, MISSING_VALUE if it isn't triangulated
+func coordinates(landmark LandmarkJSON) []string {
+	if len(landmark.Position) < 3 {
+		missing := formatCoordinate(MISSING_VALUE)
+		return []string{missing, missing, missing}
+	}
+	return []string{formatCoordinate(landmark.Position[0]), formatCoordinate(landmark.Position[1]), formatCoordinate(landmark.Position[2])}
+}
+
+// checkComplete refuses the landmarks not triangulated in the formats without notation for missing landmarks,
+// their readers would take any value written as a real position
+func checkComplete(ordered []LandmarkJSON, format string) error {
+	missing := make([]string, 0)
+	for _, landmark := range ordered {
+		if len(landmark.Position) < 3 {
+			missing = append(missing, landmark.Label)
+		}
+	}
+	if len(missing) > 0 {
+		return errorf(ERROR_INVALID_ARGUMENT, "%s has no notation for missing landmarks, %s aren't triangulated", format, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+func writeMorphologika(w io.Writer, export LandmarksExport) error {
+	ordered := orderedLandmarks(export.Landmarks)
+	if err := checkComplete(ordered, "Morphologika"); err != nil {
+		return err
+	}
+
+	buffer := bufio.NewWriter(w)
+	fmt.Fprintf(buffer, "[individuals]\n1\n[landmarks]\n%d\n[dimensions]\n3\n", len(ordered))
+	fmt.Fprintf(buffer, "[names]\n%s\n", export.Specimen)
+	fmt.Fprintf(buffer, "[rawpoints]\n'#%s\n", export.Specimen)
+	for _, landmark := range ordered {
+		fmt.Fprintln(buffer, strings.Join(coordinates(landmark), " "))
+	}
+	return buffer.Flush()
+}
+
+// NTSYS rectangular matrix, one row per specimen (with its label) and 3 columns per landmark
+func writeNTSYS(w io.Writer, export LandmarksExport) error {
+	ordered := orderedLandmarks(export.Landmarks)
+
+	missing := 0
+	for _, landmark := range ordered {
+		if len(landmark.Position) < 3 {
+			missing = 1
+		}
+	}
+
+	buffer := bufio.NewWriter(w)
+	if missing == 0 {
+		fmt.Fprintf(buffer, "1 1L %d 0 DIM=3\n", 3*len(ordered))
+	} else {
+		fmt.Fprintf(buffer, "1 1L %d 1 %d DIM=3\n", 3*len(ordered), MISSING_VALUE)
+	}
+	fmt.Fprintln(buffer, strings.ReplaceAll(export.Specimen, " ", "_"))
+	values := make([]string, 0, 3*len(ordered))
+	for _, landmark := range ordered {
+		values = append(values, coordinates(landmark)...)
+	}
+	fmt.Fprintln(buffer, strings.Join(values, " "))
+	return buffer.Flush()
+}
+
+func writeIDAV(w io.Writer, export LandmarksExport) error {
+	ordered := orderedLandmarks(export.Landmarks)
+	if err := checkComplete(ordered, "IDAV Landmark Editor"); err != nil {
+		return err
+	}
+
+	buffer := bufio.NewWriter(w)
+	fmt.Fprintf(buffer, "Version 1.0\n%d\n", len(ordered))
+	for index, landmark := range ordered {
+		fmt.Fprintf(buffer, "S%04d %s\n", index, strings.Join(coordinates(landmark), " "))
+	}
+	return buffer.Flush()
+}
+
+// cameraLabel returns the label of the camera of the image in the calibration software
+func (p *project) cameraLabel(image string) string {
+	if label, ok := p.CameraLabels[image]; ok {
+		return label
+	}
+	// projects imported before the labels were kept, the label is the name of the image
+	return strings.TrimSuffix(image, filepath.Ext(image))
+}
+
+// writeMetashapeMarkers exports the landmarks with their poses as markers,
+// positions are given back in the frame of the calibration
+func writeMetashapeMarkers(w io.Writer, export LandmarksExport) error {
+	if export.Project == nil {
+		return fmt.Errorf("no project is open")
+	}
+	inverse := export.Project.transform().Inverse()
+
+	markers := make([]imp.MarkerMetashape, 0, len(export.Landmarks.Landmarks))
+	for _, landmark := range orderedLandmarks(export.Landmarks) {
+		marker := imp.MarkerMetashape{Label: landmark.Label, Projections: make(map[string]sph.Pos)}
+		if len(landmark.Position) >= 3 {
+			reference := inverse.Apply(mat.NewVecDense(len(landmark.Position), landmark.Position))
+			marker.Reference = reference.RawVector().Data
+		}
+		for image, pose := range landmark.Poses {
+			marker.Projections[export.Project.cameraLabel(image)] = sph.Pos{X: pose.X, Y: pose.Y}
+		}
+		markers = append(markers, marker)
+	}
+	return imp.WriteMarkersMetashape(w, markers)
+}
+
+// Get the formats the landmarks can be exported to
+func (a *App) GetExportMethods() []ExportForm {
+	exports := make([]ExportForm, 0, len(EXPORTS_FILES))
+	for name, file := range EXPORTS_FILES {
+		exports = append(exports, ExportForm{Name: name, Label: file.Label, Extension: file.Extension})
+	}
+	sort.Slice(exports, func(i, j int) bool { return exports[i].Label < exports[j].Label })
+	return exports
+}
+
+// Export the landmarks with one of the EXPORTS_WRITER
+func (a *App) ExportLandmarks(projectFile string, format string, landmarks ExportJSON) error {
+	log.Printf("Export landmarks to %s\n", format)
+	file, ok := EXPORTS_FILES[format]
+	writer, okWriter := EXPORTS_WRITER[format]
+	if !ok || !okWriter {
+		return errorf(ERROR_INVALID_ARGUMENT, "unknown export format %s", format)
+	}
+
+	path, err := runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
+		DefaultDirectory: filepath.Dir(projectFile),
+		DefaultFilename:  fmt.Sprintf("landmarks_%s%s", time.Now().Format("20060102_150405"), file.Extension),
+		Filters: []runtime.FileFilter{{
+			DisplayName: fmt.Sprintf("%s (%s)", file.Label, file.Extension),
+			Pattern:     "*" + filepath.Ext(file.Extension),
+		}},
+	})
+	if err != nil {
+		return newError(ERROR_FILE, err)
+	}
+	if path == "" {
+		return nil
+	}
+
+	// the project is locked once the file is chosen, the writers use it (cameras, transform)
+	p, release, err := a.acquire(projectFile)
+	if err != nil {
+		return newError(ERROR_PROJECT, err)
+	}
+	defer release()
+
+	f, err := os.Create(path)
+	if err != nil {
+		return newError(ERROR_FILE, err)
+	}
+	defer f.Close()
+
+	landmarks.Metadata = p.metadata()
+	landmarks.Protocol = p.protocol()
+	landmarks.Measures = computeMeasures(landmarks)
+	landmarks.Curves = computeCurves(landmarks)
+	export := LandmarksExport{
+		Landmarks: landmarks,
+		Specimen:  specimenID(landmarks.Metadata, projectFile),
+		Project:   p,
+	}
+	if err := writer(f, export); err != nil {
+		f.Close()
+		os.Remove(path)
+		return newError(ERROR_FILE, err)
+	}
+	if sidecar, ok := EXPORTS_SIDECAR[format]; ok {
+		if err := sidecar(path, export); err != nil {
+			return newError(ERROR_FILE, err)
+		}
+	}
+	return nil
+}
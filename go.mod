@@ -7,6 +7,7 @@ require gonum.org/v1/gonum v0.15.1
 require (
 	github.com/wailsapp/wails/v2 v2.9.2
 	sphaeroptica.be/imports v1.0.0
+	sphaeroptica.be/morphometrics v1.0.0
 	sphaeroptica.be/photogrammetry v1.0.0
 )
 
@@ -45,4 +46,6 @@ replace sphaeroptica.be/photogrammetry v1.0.0 => ./photogrammetry
 
 replace sphaeroptica.be/imports v1.0.0 => ./imports
 
+replace sphaeroptica.be/morphometrics v1.0.0 => ./morphometrics
+
 // replace github.com/wailsapp/wails/v2 v2.9.2 => /home/psadmin/go/pkg/mod
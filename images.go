@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/jpeg"
+	_ "image/png"
+	"slices"
+	"sync"
+)
+
+// Full resolution images decoded in grayscale for the image matching, the last ones are kept.
+// Each image is decoded once without holding the cache, the calls asking for it meanwhile wait for it
+
+const GRAY_CACHE_SIZE = 8
+
+type grayEntry struct {
+	// closed once the image is decoded
+	done  chan struct{}
+	image *image.Gray
+	err   error
+}
+
+type grayCache struct {
+	mutex  sync.Mutex
+	images map[string]*grayEntry
+	order  []string
+}
+
+var grayImages = grayCache{images: make(map[string]*grayEntry)}
+
+// toGray keeps the luma of JPEG images, the other images are converted
+func toGray(img image.Image) *image.Gray {
+	switch typed := img.(type) {
+	case *image.Gray:
+		return typed
+	case *image.YCbCr:
+		return &image.Gray{Pix: typed.Y, Stride: typed.YStride, Rect: typed.Rect}
+	}
+	gray := image.NewGray(img.Bounds())
+	draw.Draw(gray, gray.Bounds(), img, img.Bounds().Min, draw.Src)
+	return gray
+}
+
+func (c *grayCache) open(file string) (*image.Gray, error) {
+	c.mutex.Lock()
+	entry, ok := c.images[file]
+	if !ok {
+		entry = &grayEntry{done: make(chan struct{})}
+		if len(c.order) == GRAY_CACHE_SIZE {
+			delete(c.images, c.order[0])
+			c.order = c.order[1:]
+		}
+		c.images[file] = entry
+		c.order = append(c.order, file)
+	}
+	c.mutex.Unlock()
+	if ok {
+		<-entry.done
+		return entry.image, entry.err
+	}
+
+	entry.image, entry.err = decodeGray(file)
+	close(entry.done)
+	if entry.err != nil {
+		// not kept, the image can be added to the archive later
+		c.forget(file, entry)
+	}
+	return entry.image, entry.err
+}
+
+func (c *grayCache) forget(file string, entry *grayEntry) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.images[file] != entry {
+		return
+	}
+	delete(c.images, file)
+	if index := slices.Index(c.order, file); index >= 0 {
+		c.order = slices.Delete(c.order, index, index+1)
+	}
+}
+
+func decodeGray(file string) (*image.Gray, error) {
+	data, err := readFile(file)
+	if err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s : %w", file, err)
+	}
+	return toGray(img), nil
+}
+
+// fullImage returns the full resolution image in grayscale, archives shared without their images have none
+func fullImage(projectFile string, image string) (*image.Gray, error) {
+	return grayImages.open(fmt.Sprintf("%s/%s", projectRoot(projectFile), image))
+}
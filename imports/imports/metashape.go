@@ -7,6 +7,7 @@ import (
 	"io"
 	"math"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -167,3 +168,117 @@ func ReadExtrinsicMetashape(file string, images map[string]string) (map[string]s
 
 	return extMap, latMin, latMax, nil
 }
+
+// Markers exported back to Metashape (File > Import > Import Markers)
+
+type MarkerMetashape struct {
+	Label string
+	// Position in the frame of the exported cameras, nil if unknown
+	Reference []float64
+	// Pixel position of the marker by camera label
+	Projections map[string]sph.Pos
+}
+
+type markersDocumentXML struct {
+	XMLName xml.Name        `xml:"document"`
+	Version string          `xml:"version,attr"`
+	Chunk   markersChunkXML `xml:"chunk"`
+}
+
+type markersChunkXML struct {
+	Cameras []markersCameraXML `xml:"cameras>camera"`
+	Markers []markerXML        `xml:"markers>marker"`
+	Frames  []markersFrameXML  `xml:"frames>frame"`
+}
+
+type markersCameraXML struct {
+	ID    int    `xml:"id,attr"`
+	Label string `xml:"label,attr"`
+}
+
+type markerXML struct {
+	ID        int                 `xml:"id,attr"`
+	Label     string              `xml:"label,attr"`
+	Reference *markerReferenceXML `xml:"reference,omitempty"`
+}
+
+type markerReferenceXML struct {
+	X       float64 `xml:"x,attr"`
+	Y       float64 `xml:"y,attr"`
+	Z       float64 `xml:"z,attr"`
+	Enabled bool    `xml:"enabled,attr"`
+}
+
+type markersFrameXML struct {
+	ID      int                  `xml:"id,attr"`
+	Markers []markerLocationsXML `xml:"markers>marker"`
+}
+
+type markerLocationsXML struct {
+	MarkerID  int                 `xml:"marker_id,attr"`
+	Locations []markerLocationXML `xml:"location"`
+}
+
+type markerLocationXML struct {
+	CameraID int     `xml:"camera_id,attr"`
+	Pinned   bool    `xml:"pinned,attr"`
+	X        float64 `xml:"x,attr"`
+	Y        float64 `xml:"y,attr"`
+}
+
+// WriteMarkersMetashape writes the markers as a Metashape markers XML,
+// cameras are listed with their labels so the projections can be matched to the cameras of the chunk
+func WriteMarkersMetashape(w io.Writer, markers []MarkerMetashape) error {
+	labels := make([]string, 0)
+	cameraIDs := make(map[string]int)
+	for _, marker := range markers {
+		for label := range marker.Projections {
+			if _, ok := cameraIDs[label]; !ok {
+				cameraIDs[label] = 0
+				labels = append(labels, label)
+			}
+		}
+	}
+	sort.Strings(labels)
+
+	chunk := markersChunkXML{}
+	for id, label := range labels {
+		cameraIDs[label] = id
+		chunk.Cameras = append(chunk.Cameras, markersCameraXML{ID: id, Label: label})
+	}
+
+	frame := markersFrameXML{ID: 0}
+	for id, marker := range markers {
+		markerData := markerXML{ID: id, Label: marker.Label}
+		if len(marker.Reference) >= 3 {
+			// the reference is informative, it must not constrain the alignment
+			markerData.Reference = &markerReferenceXML{X: marker.Reference[0], Y: marker.Reference[1], Z: marker.Reference[2], Enabled: false}
+		}
+		chunk.Markers = append(chunk.Markers, markerData)
+
+		cameras := make([]string, 0, len(marker.Projections))
+		for label := range marker.Projections {
+			cameras = append(cameras, label)
+		}
+		sort.Strings(cameras)
+
+		locations := markerLocationsXML{MarkerID: id}
+		for _, label := range cameras {
+			pos := marker.Projections[label]
+			locations.Locations = append(locations.Locations, markerLocationXML{CameraID: cameraIDs[label], Pinned: true, X: pos.X, Y: pos.Y})
+		}
+		frame.Markers = append(frame.Markers, locations)
+	}
+	chunk.Frames = []markersFrameXML{frame}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(markersDocumentXML{Version: "1.2.0", Chunk: chunk}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
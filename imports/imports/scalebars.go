@@ -0,0 +1,64 @@
+package imports
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+type ScaleBar struct {
+	Label  string
+	Left   string
+	Right  string
+	Length float64
+}
+
+// Read scale bars between pairs of (coded) targets
+// Each line is "left,right,length" or "label,left,right,length", lines starting with # are ignored
+// as well as a header line
+func ReadScaleBars(file string) ([]ScaleBar, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	csvReader := csv.NewReader(f)
+	csvReader.Comment = '#'
+	csvReader.FieldsPerRecord = -1
+	csvReader.TrimLeadingSpace = true
+
+	bars := []ScaleBar{}
+	for line := 1; ; line++ {
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(record) != 3 && len(record) != 4 {
+			return nil, fmt.Errorf("line %d : expected 3 or 4 fields, got %d", line, len(record))
+		}
+		length, err := strconv.ParseFloat(strings.TrimSpace(record[len(record)-1]), 64)
+		if err != nil {
+			if line == 1 {
+				// header
+				continue
+			}
+			return nil, fmt.Errorf("line %d : %w", line, err)
+		}
+
+		bar := ScaleBar{Left: record[len(record)-3], Right: record[len(record)-2], Length: length}
+		if len(record) == 4 {
+			bar.Label = record[0]
+		} else {
+			bar.Label = fmt.Sprintf("%s_%s", bar.Left, bar.Right)
+		}
+		bars = append(bars, bar)
+	}
+	return bars, nil
+}
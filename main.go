@@ -4,7 +4,6 @@ import (
 	"embed"
 	"fmt"
 	"net/http"
-	"os"
 
 	"github.com/wailsapp/wails/v2"
 	"github.com/wailsapp/wails/v2/pkg/options"
@@ -30,10 +29,11 @@ func (h *FileLoader) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 	var err error
 	requestedFilename := req.URL.Path // strings.TrimPrefix(req.URL.Path, "/")
 	println("Requesting file:", requestedFilename)
-	fileData, err := os.ReadFile(requestedFilename)
+	fileData, err := readFile(requestedFilename)
 	if err != nil {
 		res.WriteHeader(http.StatusBadRequest)
 		res.Write([]byte(fmt.Sprintf("Could not load file %s", requestedFilename)))
+		return
 	}
 
 	res.Write(fileData)
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"strings"
+
+	"gonum.org/v1/gonum/mat"
+	sph "sphaeroptica.be/photogrammetry/photogrammetry"
+)
+
+// Measures beyond the two landmarks distances, angles are in degrees
+
+const (
+	MEASURE_DISTANCE    = "distance"
+	MEASURE_ANGLE       = "angle"
+	MEASURE_DIHEDRAL    = "dihedral"
+	MEASURE_POLYLINE    = "polyline"
+	MEASURE_AREA        = "area"
+	MEASURE_POINT_PLANE = "pointPlane"
+)
+
+type measureType struct {
+	// number of landmarks, Max is 0 for any number above Min
+	Min int
+	Max int
+	// power of the length unit of the value (0 for the angles)
+	Dimension int
+}
+
+var MEASURES_TYPES = map[string]measureType{
+	MEASURE_DISTANCE: {Min: 2, Max: 2, Dimension: 1},
+	// the vertex is the second landmark
+	MEASURE_ANGLE: {Min: 3, Max: 3, Dimension: 0},
+	// around the axis between the second and third landmarks
+	MEASURE_DIHEDRAL: {Min: 4, Max: 4, Dimension: 0},
+	MEASURE_POLYLINE: {Min: 2, Max: 0, Dimension: 1},
+	MEASURE_AREA:     {Min: 3, Max: 0, Dimension: 2},
+	// distance of the first landmark to the plane of the three others
+	MEASURE_POINT_PLANE: {Min: 4, Max: 4, Dimension: 1},
+}
+
+// computeMeasure computes the value of the measure from the triangulated landmarks,
+// the landmarks not triangulated are listed as missing. The value is missing (nil) as well
+// if the measure can't be computed, for a degenerate geometry (aligned landmarks...)
+func computeMeasure(landmarks ExportJSON, measure MeasureJSON) (MeasureJSON, error) {
+	measure.Value = nil
+	measure.Error = ""
+	measure.Missing = []string{}
+
+	if err := checkMeasure(measure); err != nil {
+		return measure, err
+	}
+
+	points := make([]mat.Vector, 0, len(measure.Landmarks))
+	for _, reference := range measure.Landmarks {
+		landmark, ok := findLandmark(landmarks, reference)
+		if !ok || len(landmark.Position) < 3 {
+			measure.Missing = append(measure.Missing, reference)
+			continue
+		}
+		points = append(points, mat.NewVecDense(len(landmark.Position), landmark.Position))
+	}
+	if len(measure.Missing) != 0 {
+		return measure, nil
+	}
+
+	var value float64
+	var err error
+	switch measure.Type {
+	case MEASURE_DISTANCE:
+		value = sph.Distance(points[0], points[1])
+	case MEASURE_ANGLE:
+		value, err = sph.Angle(points[0], points[1], points[2])
+		value = sph.Rad2Degrees(value)
+	case MEASURE_DIHEDRAL:
+		value, err = sph.DihedralAngle(points[0], points[1], points[2], points[3])
+		value = sph.Rad2Degrees(value)
+	case MEASURE_POLYLINE:
+		value = sph.PolylineLength(points)
+	case MEASURE_AREA:
+		value, err = sph.PolygonArea(points)
+	case MEASURE_POINT_PLANE:
+		value, err = sph.PointPlaneDistance(points[0], points[1], points[2], points[3])
+	}
+	if err != nil {
+		measure.Error = err.Error()
+		return measure, fmt.Errorf("measure %s : %w", measure.Label, err)
+	}
+	measure.Value = &value
+	return measure, nil
+}
+
+// checkMeasure returns an error if the type of the measure is unknown or doesn't fit its number of landmarks
+func checkMeasure(measure MeasureJSON) error {
+	kind, ok := MEASURES_TYPES[measure.Type]
+	if !ok {
+		return fmt.Errorf("measure %s : unknown type %s", measure.Label, measure.Type)
+	}
+	count := len(measure.Landmarks)
+	if count < kind.Min || (kind.Max != 0 && count > kind.Max) {
+		return fmt.Errorf("measure %s : a %s needs %d landmarks, got %d", measure.Label, measure.Type, kind.Min, count)
+	}
+	return nil
+}
+
+// computeMeasures computes the measures again from the current positions of the landmarks
+func computeMeasures(landmarks ExportJSON) []MeasureJSON {
+	measures := make([]MeasureJSON, len(landmarks.Measures))
+	for index, measure := range landmarks.Measures {
+		computed, err := computeMeasure(landmarks, measure)
+		if err != nil {
+			log.Println(err)
+		}
+		measures[index] = computed
+	}
+	return measures
+}
+
+// measuresWithDistances returns the distances as measures, followed by the other measures
+func measuresWithDistances(landmarks ExportJSON) []MeasureJSON {
+	measures := make([]MeasureJSON, 0, len(landmarks.Distances)+len(landmarks.Measures))
+	for _, distance := range landmarks.Distances {
+		measure, err := computeMeasure(landmarks, MeasureJSON{Label: distance.Label, Type: MEASURE_DISTANCE, Landmarks: []string{distance.Left, distance.Right}})
+		if err != nil {
+			log.Println(err)
+		}
+		measures = append(measures, measure)
+	}
+	return append(measures, computeMeasures(landmarks)...)
+}
+
+// writeMeasures writes the distances and measures as a CSV table, the adjusted values are scaled by the scale factor.
+// The measures that can't be computed are written as NA
+func writeMeasures(w io.Writer, export LandmarksExport) error {
+	writer := csv.NewWriter(w)
+	err := writer.Write([]string{"Label", "Type", "Landmarks", "Value", "Value_adjusted"})
+	if err != nil {
+		return err
+	}
+	for _, measure := range measuresWithDistances(export.Landmarks) {
+		value, adjusted := MISSING_TEXT, MISSING_TEXT
+		if measure.Value != nil {
+			scale := 1.0
+			if export.Landmarks.ScaleFactor != 0 {
+				scale = math.Pow(export.Landmarks.ScaleFactor, float64(MEASURES_TYPES[measure.Type].Dimension))
+			}
+			value, adjusted = formatCoordinate(*measure.Value), formatCoordinate(scale*(*measure.Value))
+		}
+		err := writer.Write([]string{measure.Label, measure.Type, strings.Join(measure.Landmarks, ";"), value, adjusted})
+		if err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// Compute the measures from the landmarks, to be called again when a landmark moves.
+// The measures that can't be computed (landmarks missing, degenerate geometry) have no value
+func (a *App) ComputeMeasures(projectFile string, landmarks ExportJSON) ([]MeasureJSON, error) {
+	_, release, err := a.acquire(projectFile)
+	if err != nil {
+		return []MeasureJSON{}, newError(ERROR_PROJECT, err)
+	}
+	release()
+
+	for _, measure := range landmarks.Measures {
+		if err := checkMeasure(measure); err != nil {
+			return []MeasureJSON{}, newError(ERROR_INVALID_ARGUMENT, err)
+		}
+	}
+	return computeMeasures(landmarks), nil
+}
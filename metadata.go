@@ -0,0 +1,59 @@
+package main
+
+// Term is a metadata field as it is written in the exports
+type Term struct {
+	Name  string
+	Value string
+}
+
+// Terms flattens the metadata with their Darwin Core / Audubon Core names
+func (m Metadata) Terms() []Term {
+	return []Term{
+		{Name: "dwc:catalogNumber", Value: m.Specimen.CatalogNumber},
+		{Name: "dwc:institutionCode", Value: m.Specimen.InstitutionCode},
+		{Name: "dwc:scientificName", Value: m.Specimen.ScientificName},
+		{Name: "ac:captureDevice", Value: m.Acquisition.Rig},
+		{Name: "dc:creator", Value: m.Acquisition.Operator},
+		{Name: "xmp:CreateDate", Value: m.Acquisition.Date},
+		{Name: "ac:resourceCreationTechnique", Value: m.Acquisition.StackingSoftware},
+		{Name: "xmpRights:UsageTerms", Value: m.Licensing.License},
+		{Name: "xmpRights:Owner", Value: m.Licensing.Owner},
+		{Name: "photoshop:Credit", Value: m.Licensing.Credit},
+	}
+}
+
+// metadata returns a copy of the metadata of the project, carried into the exports, nil without project
+func (p *project) metadata() *Metadata {
+	if p == nil {
+		return nil
+	}
+	metadata := p.Metadata
+	return &metadata
+}
+
+// Get the metadata of the project
+func (a *App) Metadata(projectFile string) (Metadata, error) {
+	p, release, err := a.acquire(projectFile)
+	if err != nil {
+		return Metadata{}, newError(ERROR_PROJECT, err)
+	}
+	defer release()
+
+	return p.Metadata, nil
+}
+
+// Set the metadata of the project and save it
+func (a *App) SetMetadata(projectFile string, metadata Metadata) error {
+	p, release, err := a.acquire(projectFile)
+	if err != nil {
+		return newError(ERROR_PROJECT, err)
+	}
+	defer release()
+
+	p.Metadata = metadata
+	err = saveProjectFile(projectFile, p)
+	if err != nil {
+		return newError(ERROR_PROJECT, err)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"log"
+	"slices"
+	"sort"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/gonum/stat"
+	morph "sphaeroptica.be/morphometrics/morphometrics"
+)
+
+// Specimens further from the consensus than the upper quartile of the distances plus OUTLIER_IQR times
+// the interquartile range (the rule of geomorph plotOutliers), robust to the outliers themselves.
+// The quartiles need OUTLIER_MIN_SPECIMENS at least, no specimen is flagged below
+const OUTLIER_IQR = 1.5
+const OUTLIER_MIN_SPECIMENS = 4
+
+// outlierThreshold returns the Procrustes distance above which a specimen is an outlier,
+// false if there are too few specimens to tell
+func outlierThreshold(distances []float64) (float64, bool) {
+	if len(distances) < OUTLIER_MIN_SPECIMENS {
+		return 0, false
+	}
+	sorted := slices.Clone(distances)
+	sort.Float64s(sorted)
+	lower := stat.Quantile(0.25, stat.LinInterp, sorted, nil)
+	upper := stat.Quantile(0.75, stat.LinInterp, sorted, nil)
+	return upper + OUTLIER_IQR*(upper-lower), true
+}
+
+// configuration returns the landmarks (k x 3) of the specimen in the order of the template,
+// false if one of them isn't triangulated
+func configuration(template []string, landmarks ExportJSON) (*mat.Dense, bool) {
+	positions := exportedPositions(landmarks)
+	data := mat.NewDense(len(template), 3, nil)
+	for index, label := range template {
+		position, ok := positions[label]
+		if !ok {
+			return nil, false
+		}
+		data.SetRow(index, position[:3])
+	}
+	return data, true
+}
+
+// procrustesEntries replaces the landmarks of the specimens by their Procrustes coordinates
+func procrustesEntries(template []string, entries []batchEntry, result morph.GPAResult) []batchEntry {
+	aligned := make([]batchEntry, len(entries))
+	for index, entry := range entries {
+		landmarks := make(map[string]LandmarkJSON)
+		for row, label := range template {
+			landmark, _ := findLandmark(entry.Landmarks, label)
+			landmark.Position = mat.Row(nil, row, result.Aligned[index])
+			landmarks[label] = landmark
+		}
+		entry.Landmarks.Landmarks = landmarks
+		entry.Landmarks.Distances = []DistanceJSON{}
+		// the semilandmarks aren't part of the analysis
+		entry.Landmarks.Curves = []CurveJSON{}
+		// Procrustes coordinates have no unit
+		entry.Landmarks.ScaleFactor = 0
+		aligned[index] = entry
+	}
+	return aligned
+}
+
+// Generalized Procrustes Analysis of the last landmark session of every project of a directory,
+// the aligned coordinates are saved to one of the BATCH_EXPORTS unless format is empty
+func (a *App) ProcrustesAnalysis(format string) (ProcrustesReport, error) {
+	log.Println("Procrustes analysis")
+	if _, ok := BATCH_EXPORTS[format]; format != "" && !ok {
+		return ProcrustesReport{}, errorf(ERROR_INVALID_ARGUMENT, "unknown batch format %s", format)
+	}
+
+	dir := a.openDirectoryDialog("Select Projects Folder", []runtime.FileFilter{})
+	if dir == "" {
+		return ProcrustesReport{}, nil
+	}
+	entries, skipped, err := collectSpecimens(dir)
+	if err != nil {
+		return ProcrustesReport{}, newError(ERROR_FILE, err)
+	}
+	template, matching := batchTemplate(entries)
+	if len(template) == 0 {
+		return ProcrustesReport{Skipped: skipped}, errorf(ERROR_INVALID_ARGUMENT, "no landmark to align in the sessions of %s", dir)
+	}
+
+	// GPA needs every landmark, the incomplete specimens and the ones not following the template are left out
+	report := ProcrustesReport{Template: template, Specimens: []ProcrustesSpecimen{}, Excluded: []BatchSpecimen{}, Skipped: skipped}
+	for _, entry := range entries {
+		if entry.Report.Mismatch != "" {
+			report.Excluded = append(report.Excluded, entry.Report)
+		}
+	}
+	complete := make([]batchEntry, 0, len(matching))
+	configurations := make([]mat.Matrix, 0, len(matching))
+	for _, entry := range matching {
+		data, ok := configuration(template, entry.Landmarks)
+		if !ok {
+			report.Excluded = append(report.Excluded, entry.Report)
+			continue
+		}
+		complete = append(complete, entry)
+		configurations = append(configurations, data)
+	}
+
+	result, err := morph.GPA(configurations)
+	if err != nil {
+		return report, errorf(ERROR_COMPUTATION, "%d complete specimens : %w", len(configurations), err)
+	}
+	report.Iterations = result.Iterations
+	rows, _ := result.Mean.Dims()
+	report.Mean = make([][]float64, rows)
+	for row := range report.Mean {
+		report.Mean[row] = mat.Row(nil, row, result.Mean)
+	}
+
+	threshold, flagged := outlierThreshold(result.Distances)
+	for index, entry := range complete {
+		report.Specimens = append(report.Specimens, ProcrustesSpecimen{
+			Specimen:     entry.Report.Specimen,
+			Project:      entry.Report.Project,
+			CentroidSize: result.CentroidSizes[index],
+			Distance:     result.Distances[index],
+			Outlier:      flagged && result.Distances[index] > threshold,
+		})
+	}
+
+	if format == "" {
+		return report, nil
+	}
+	output, err := a.saveBatch(dir, format, template, procrustesEntries(template, complete, result))
+	if err != nil {
+		return report, newError(ERROR_FILE, err)
+	}
+	report.Output = output
+	return report, nil
+}
@@ -0,0 +1,5 @@
+module sphaeroptica.be/morphometrics
+
+go 1.23.4
+
+require gonum.org/v1/gonum v0.15.1
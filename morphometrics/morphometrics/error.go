@@ -0,0 +1,89 @@
+package morphometrics
+
+import (
+	"errors"
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+var ErrNotEnoughRepeats = errors.New("at least 2 subjects measured at least twice are needed")
+
+// Dispersion of the repeated positions of a landmark
+//
+// Args:
+// positions ([]mat.Vector): the positions of the landmark in every session
+//
+//	Returns:
+//	*mat.VecDense: the mean position
+//	float64: root mean square distance of the positions to the mean
+//	float64: largest distance of a position to the mean
+func Dispersion(positions []mat.Vector) (*mat.VecDense, float64, float64) {
+	mean := mat.NewVecDense(3, nil)
+	if len(positions) == 0 {
+		return mean, 0, 0
+	}
+	for _, position := range positions {
+		mean.AddVec(mean, point3(position))
+	}
+	mean.ScaleVec(1/float64(len(positions)), mean)
+
+	sum := 0.0
+	max := 0.0
+	for _, position := range positions {
+		var diff mat.VecDense
+		diff.SubVec(point3(position), mean)
+		distance := diff.Norm(2)
+		sum += distance * distance
+		max = math.Max(max, distance)
+	}
+	return mean, math.Sqrt(sum / float64(len(positions))), max
+}
+
+// Intraclass correlation ICC(2,1) of Shrout and Fleiss (1979):
+// two-way random effects, absolute agreement, single measurement
+//
+// Args:
+// data (mat.Matrix): n subjects x k repeated measurements (sessions or observers)
+//
+//	Returns:
+//	float64: the part of the variance due to the subjects rather than to the measurement error
+func ICC(data mat.Matrix) (float64, error) {
+	n, k := data.Dims()
+	if n < 2 || k < 2 {
+		return 0, ErrNotEnoughRepeats
+	}
+
+	dense := mat.DenseCopyOf(data)
+	grand := mat.Sum(dense) / float64(n*k)
+	ssRows, ssCols, ssTotal := 0.0, 0.0, 0.0
+	for row := 0; row < n; row++ {
+		mean := mat.Sum(dense.RowView(row)) / float64(k)
+		ssRows += float64(k) * (mean - grand) * (mean - grand)
+	}
+	for col := 0; col < k; col++ {
+		mean := mat.Sum(dense.ColView(col)) / float64(n)
+		ssCols += float64(n) * (mean - grand) * (mean - grand)
+	}
+	for row := 0; row < n; row++ {
+		for col := 0; col < k; col++ {
+			ssTotal += (dense.At(row, col) - grand) * (dense.At(row, col) - grand)
+		}
+	}
+	ssError := ssTotal - ssRows - ssCols
+
+	msRows := ssRows / float64(n-1)
+	msCols := ssCols / float64(k-1)
+	msError := ssError / float64((n-1)*(k-1))
+
+	denominator := msRows + float64(k-1)*msError + float64(k)*(msCols-msError)/float64(n)
+	if denominator == 0 {
+		return 0, ErrNotEnoughRepeats
+	}
+	return (msRows - msError) / denominator, nil
+}
+
+// point3 drops the homogeneous coordinate of a position
+func point3(position mat.Vector) *mat.VecDense {
+	return mat.NewVecDense(3, []float64{position.AtVec(0), position.AtVec(1), position.AtVec(2)})
+}
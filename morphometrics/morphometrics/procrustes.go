@@ -0,0 +1,175 @@
+package morphometrics
+
+import (
+	"errors"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+var ErrNotEnoughSpecimens = errors.New("at least 2 specimens are needed")
+var ErrLandmarksCount = errors.New("every configuration must have the same landmarks")
+var ErrDegenerateConfiguration = errors.New("the landmarks of a configuration are all at the same position")
+
+const GPA_TOLERANCE = 1e-10
+const GPA_MAX_ITERATIONS = 100
+
+// Result of a Generalized Procrustes Analysis
+type GPAResult struct {
+	// Procrustes coordinates (k x 3) of every configuration, centered and of unit centroid size
+	Aligned []*mat.Dense
+	// Consensus (k x 3) of the aligned configurations
+	Mean *mat.Dense
+	// Centroid size of every configuration before its scaling
+	CentroidSizes []float64
+	// Procrustes distance of every configuration to the consensus
+	Distances  []float64
+	Iterations int
+}
+
+// Center a configuration (k x 3) on its centroid
+func Center(configuration mat.Matrix) *mat.Dense {
+	rows, cols := configuration.Dims()
+	centered := mat.DenseCopyOf(configuration)
+	for col := 0; col < cols; col++ {
+		mean := mat.Sum(centered.ColView(col)) / float64(rows)
+		for row := 0; row < rows; row++ {
+			centered.Set(row, col, centered.At(row, col)-mean)
+		}
+	}
+	return centered
+}
+
+// Square root of the sum of the squared distances of the landmarks to their centroid
+func CentroidSize(configuration mat.Matrix) float64 {
+	return mat.Norm(Center(configuration), 2)
+}
+
+// optimalRotation returns the rotation R (3x3) minimizing |X * R - Y|, with X and Y centered
+func optimalRotation(X mat.Matrix, Y mat.Matrix) *mat.Dense {
+	var cross mat.Dense
+	cross.Mul(X.T(), Y)
+
+	var svd mat.SVD
+	svd.Factorize(&cross, mat.SVDFull)
+	var U, V mat.Dense
+	svd.UTo(&U)
+	svd.VTo(&V)
+
+	// reflection guard
+	signs := []float64{1, 1, 1}
+	if mat.Det(&U)*mat.Det(&V) < 0 {
+		signs[2] = -1
+	}
+
+	var rotation mat.Dense
+	rotation.Mul(&U, mat.NewDiagDense(3, signs))
+	rotation.Mul(&rotation, V.T())
+	return &rotation
+}
+
+// rotateOnto rotates the centered configuration X onto the centered configuration Y
+func rotateOnto(X mat.Matrix, Y mat.Matrix) *mat.Dense {
+	var rotated mat.Dense
+	rotated.Mul(X, optimalRotation(X, Y))
+	return &rotated
+}
+
+// normalize centers the configuration and scales it to unit centroid size
+func normalize(configuration mat.Matrix) (*mat.Dense, float64, error) {
+	centered := Center(configuration)
+	size := mat.Norm(centered, 2)
+	if size == 0 {
+		return nil, 0, ErrDegenerateConfiguration
+	}
+	centered.Scale(1/size, centered)
+	return centered, size, nil
+}
+
+// Partial Procrustes distance between two configurations:
+// both are centered and scaled to unit centroid size, and b is rotated onto a
+func ProcrustesDistance(a mat.Matrix, b mat.Matrix) (float64, error) {
+	normA, _, err := normalize(a)
+	if err != nil {
+		return 0, err
+	}
+	normB, _, err := normalize(b)
+	if err != nil {
+		return 0, err
+	}
+	var diff mat.Dense
+	diff.Sub(rotateOnto(normB, normA), normA)
+	return mat.Norm(&diff, 2), nil
+}
+
+// Generalized Procrustes Analysis of 3D landmark configurations
+// Every configuration is centered and scaled to unit centroid size, then the configurations are
+// rotated onto their consensus, which is computed again until it converges
+//
+// Args:
+// configurations ([]mat.Matrix): k x 3 landmarks of every specimen, in the same order
+//
+//	Returns:
+//	GPAResult: the aligned configurations, their consensus, centroid sizes and Procrustes distances
+func GPA(configurations []mat.Matrix) (GPAResult, error) {
+	n := len(configurations)
+	if n < 2 {
+		return GPAResult{}, ErrNotEnoughSpecimens
+	}
+	k, dims := configurations[0].Dims()
+	if k < 3 || dims != 3 {
+		return GPAResult{}, ErrLandmarksCount
+	}
+
+	result := GPAResult{
+		Aligned:       make([]*mat.Dense, n),
+		CentroidSizes: make([]float64, n),
+		Distances:     make([]float64, n),
+	}
+	for index, configuration := range configurations {
+		rows, cols := configuration.Dims()
+		if rows != k || cols != dims {
+			return GPAResult{}, ErrLandmarksCount
+		}
+		normalized, size, err := normalize(configuration)
+		if err != nil {
+			return GPAResult{}, err
+		}
+		result.Aligned[index] = normalized
+		result.CentroidSizes[index] = size
+	}
+
+	mean := mat.DenseCopyOf(result.Aligned[0])
+	for result.Iterations < GPA_MAX_ITERATIONS {
+		result.Iterations++
+		for index, aligned := range result.Aligned {
+			result.Aligned[index] = rotateOnto(aligned, mean)
+		}
+
+		consensus := mat.NewDense(k, dims, nil)
+		for _, aligned := range result.Aligned {
+			consensus.Add(consensus, aligned)
+		}
+		consensus, _, err := normalize(consensus)
+		if err != nil {
+			return GPAResult{}, err
+		}
+
+		var diff mat.Dense
+		diff.Sub(consensus, mean)
+		change := mat.Norm(&diff, 2)
+		mean = consensus
+		if change*change < GPA_TOLERANCE {
+			break
+		}
+	}
+
+	// rotate onto the final consensus
+	for index, aligned := range result.Aligned {
+		result.Aligned[index] = rotateOnto(aligned, mean)
+		var diff mat.Dense
+		diff.Sub(result.Aligned[index], mean)
+		result.Distances[index] = mat.Norm(&diff, 2)
+	}
+	result.Mean = mean
+	return result, nil
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+
+	"gonum.org/v1/gonum/mat"
+	sph "sphaeroptica.be/photogrammetry/photogrammetry"
+)
+
+// Anatomical directions in the specimen frame (see sph.FrameFromLandmarks)
+var ANATOMICAL_DIRECTIONS = []struct {
+	Name      string
+	Direction []float64
+}{
+	{Name: "FRONT", Direction: []float64{1, 0, 0}},
+	{Name: "POST", Direction: []float64{-1, 0, 0}},
+	{Name: "LEFT", Direction: []float64{0, 1, 0}},
+	{Name: "RIGHT", Direction: []float64{0, -1, 0}},
+	{Name: "SUPERIOR", Direction: []float64{0, 0, 1}},
+	{Name: "INFERIOR", Direction: []float64{0, 0, -1}},
+}
+
+// orientation returns the rotation from the world frame to the specimen frame, nil if the project isn't oriented
+func (p *project) orientation() *mat.Dense {
+	if p.Orientation == nil {
+		return nil
+	}
+	return mat.NewDense(p.Orientation.Shape.Row, p.Orientation.Shape.Col, p.Orientation.Data)
+}
+
+// cameraCenter returns the position of the camera of the image in the project frame
+func (p *project) cameraCenter(image string) mat.Vector {
+	extrinsicsMat := p.extrinsics(image)
+	rotationMat := mat.DenseCopyOf(extrinsicsMat.Slice(0, 3, 0, 3))
+	transMat := mat.DenseCopyOf(extrinsicsMat.Slice(0, 3, 3, 4))
+	return sph.GetCameraWorldsCoordinates(rotationMat, transMat)
+}
+
+// cameraVectors returns the vector from the center of the sphere to every camera,
+// expressed in the specimen frame if the project is oriented
+func (p *project) cameraVectors() map[string]*mat.VecDense {
+	keys := make([]string, 0, len(p.Extrinsics))
+	for k := range p.Extrinsics {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	centers := make(map[string]mat.Vector)
+
+	var centersX []float64
+	var centersY []float64
+	var centersZ []float64
+
+	for _, image := range keys {
+		worldCoord := p.cameraCenter(image)
+		centersX = append(centersX, worldCoord.AtVec(0))
+		centersY = append(centersY, worldCoord.AtVec(1))
+		centersZ = append(centersZ, worldCoord.AtVec(2))
+		centers[image] = worldCoord
+	}
+
+	vectors := make(map[string]*mat.VecDense)
+	if len(keys) == 0 {
+		return vectors
+	}
+
+	_, center := sph.SphereFit(centersX, centersY, centersZ)
+
+	var centerVecDense mat.VecDense
+	centerVecDense.CloneFromVec(center)
+	centerVec := centerVecDense.SliceVec(0, 3)
+	log.Printf("Center = %v\n\n", sph.FormatMatrixPrint(centerVec))
+
+	rotation := p.orientation()
+	for image, C := range centers {
+		var vector mat.VecDense
+		vector.SubVec(C, centerVec)
+		if rotation != nil {
+			var rotated mat.VecDense
+			rotated.MulVec(rotation, &vector)
+			vector = rotated
+		}
+		vectors[image] = &vector
+	}
+	return vectors
+}
+
+// imageCoordinates returns the geographic coordinates (in degrees) of every image
+func (p *project) imageCoordinates() map[string]sph.Coordinates {
+	coordinates := make(map[string]sph.Coordinates)
+	for image, vector := range p.cameraVectors() {
+		coordinates[image] = vectorCoordinates(vector)
+	}
+	return coordinates
+}
+
+func vectorCoordinates(vector mat.Vector) sph.Coordinates {
+	long, lat := sph.GetLongLat(*mat.VecDenseCopyOf(vector))
+	return sph.Coordinates{
+		Longitude: sph.Rad2Degrees(long),
+		Latitude:  sph.Rad2Degrees(lat)}
+}
+
+// orientedCommands computes the anatomical views in the specimen frame,
+// latitudes are limited to the ones covered by the images
+func orientedCommands(latMin float64, latMax float64) map[string]sph.Coordinates {
+	commands := make(map[string]sph.Coordinates)
+	for _, anatomical := range ANATOMICAL_DIRECTIONS {
+		coordinates := vectorCoordinates(mat.NewVecDense(3, anatomical.Direction))
+		coordinates.Latitude = math.Max(latMin, math.Min(latMax, coordinates.Latitude))
+		commands[anatomical.Name] = coordinates
+	}
+	return commands
+}
+
+// setOrientation changes the specimen frame of the project and re-derives its views,
+// the anatomical views are recomputed and the other ones keep pointing to the same direction
+func (p *project) setOrientation(rotation *mat.Dense) {
+	previous := p.orientation()
+	order := p.commandsOrder()
+
+	custom := make(map[string]mat.Vector)
+	for name, coordinates := range p.Commands {
+		var vector mat.Vector = sph.LongLatToVector(sph.Degrees2Rad(coordinates.Longitude), sph.Degrees2Rad(coordinates.Latitude))
+		if previous != nil {
+			var world mat.VecDense
+			world.MulVec(previous.T(), vector)
+			vector = &world
+		}
+		if rotation != nil {
+			var oriented mat.VecDense
+			oriented.MulVec(rotation, vector)
+			vector = &oriented
+		}
+		custom[name] = vector
+	}
+
+	p.invalidate()
+	if rotation == nil {
+		p.Orientation = nil
+	} else {
+		rows, cols := rotation.Dims()
+		p.Orientation = &sph.MatrixInfo{Shape: sph.Shape{Row: rows, Col: cols}, Data: mat.DenseCopyOf(rotation).RawMatrix().Data}
+	}
+
+	latMin := 90.0
+	latMax := -90.0
+	for _, coordinates := range p.imageCoordinates() {
+		latMin = math.Min(latMin, coordinates.Latitude)
+		latMax = math.Max(latMax, coordinates.Latitude)
+	}
+
+	var anatomical map[string]sph.Coordinates
+	var anatomicalOrder []string
+	if rotation == nil {
+		anatomical, anatomicalOrder = defaultCommands(latMin, latMax)
+	} else {
+		anatomical = orientedCommands(latMin, latMax)
+		for _, direction := range ANATOMICAL_DIRECTIONS {
+			anatomicalOrder = append(anatomicalOrder, direction.Name)
+		}
+	}
+
+	commands := make(map[string]sph.Coordinates)
+	for name, vector := range custom {
+		commands[name] = vectorCoordinates(vector)
+	}
+	for _, name := range anatomicalOrder {
+		if _, ok := commands[name]; !ok {
+			order = append(order, name)
+		}
+		commands[name] = anatomical[name]
+	}
+	p.Commands = commands
+	p.CommandsOrder = order
+}
+
+// Orient the specimen from an anterior, a posterior and a superior landmark
+func (a *App) OrientFromLandmarks(projectFile string, anterior []float64, posterior []float64, superior []float64) ([]View, error) {
+	return a.editViews(projectFile, func(p *project) error {
+		if len(anterior) < 3 || len(posterior) < 3 || len(superior) < 3 {
+			return fmt.Errorf("the three landmarks must be triangulated")
+		}
+		rotation, err := sph.FrameFromLandmarks(mat.NewVecDense(len(anterior), anterior), mat.NewVecDense(len(posterior), posterior), mat.NewVecDense(len(superior), superior))
+		if err != nil {
+			return newError(ERROR_COMPUTATION, err)
+		}
+		p.setOrientation(rotation)
+		return nil
+	})
+}
+
+// Orient the specimen from the principal axes of its triangulated landmarks
+func (a *App) OrientFromPCA(projectFile string, landmarks [][]float64) ([]View, error) {
+	return a.editViews(projectFile, func(p *project) error {
+		points := make([]mat.Vector, 0, len(landmarks))
+		for _, landmark := range landmarks {
+			if len(landmark) >= 3 {
+				points = append(points, mat.NewVecDense(len(landmark), landmark))
+			}
+		}
+		rotation, err := sph.FrameFromPCA(points)
+		if err != nil {
+			return newError(ERROR_COMPUTATION, err)
+		}
+		p.setOrientation(rotation)
+		return nil
+	})
+}
+
+// Go back to the frame of the calibration
+func (a *App) ResetOrientation(projectFile string) ([]View, error) {
+	return a.editViews(projectFile, func(p *project) error {
+		p.setOrientation(nil)
+		return nil
+	})
+}
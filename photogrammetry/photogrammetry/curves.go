@@ -0,0 +1,115 @@
+package photogrammetry
+
+import (
+	"errors"
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+var ErrNotEnoughControlPoints = errors.New("a curve needs at least 2 distinct points")
+
+// number of points computed on the spline between two control points
+const CURVE_SAMPLES = 100
+
+// point of the centripetal Catmull-Rom segment between p1 and p2 (Barry and Goldman's pyramidal formulation)
+func catmullRomPoint(p [4][]float64, knots [4]float64, t float64) []float64 {
+	lerp := func(a []float64, b []float64, ta float64, tb float64) []float64 {
+		wa, wb := (tb-t)/(tb-ta), (t-ta)/(tb-ta)
+		return []float64{wa*a[0] + wb*b[0], wa*a[1] + wb*b[1], wa*a[2] + wb*b[2]}
+	}
+	a1 := lerp(p[0], p[1], knots[0], knots[1])
+	a2 := lerp(p[1], p[2], knots[1], knots[2])
+	a3 := lerp(p[2], p[3], knots[2], knots[3])
+	b1 := lerp(a1, a2, knots[0], knots[2])
+	b2 := lerp(a2, a3, knots[1], knots[3])
+	return lerp(b1, b2, knots[1], knots[2])
+}
+
+// Centripetal Catmull-Rom spline through the control points (homogeneous coordinates are dropped),
+// which doesn't overshoot nor loop between close points
+//
+// Args:
+// points ([]mat.Vector): control points in order
+// samples (int): number of points computed between two control points
+//
+//	Returns:
+//	[]*mat.VecDense: polyline following the spline, from the first to the last control point
+func CatmullRom(points []mat.Vector, samples int) ([]*mat.VecDense, error) {
+	control := make([][]float64, 0, len(points)+2)
+	for _, point := range points {
+		p := vec3(point).RawVector().Data
+		if len(control) > 0 && Distance(mat.NewVecDense(3, control[len(control)-1]), mat.NewVecDense(3, p)) < frameEpsilon {
+			continue
+		}
+		control = append(control, p)
+	}
+	n := len(control)
+	if n < 2 {
+		return nil, ErrNotEnoughControlPoints
+	}
+
+	// phantom points continuing the first and last segments
+	first := []float64{2*control[0][0] - control[1][0], 2*control[0][1] - control[1][1], 2*control[0][2] - control[1][2]}
+	last := []float64{2*control[n-1][0] - control[n-2][0], 2*control[n-1][1] - control[n-2][1], 2*control[n-1][2] - control[n-2][2]}
+	control = append([][]float64{first}, append(control, last)...)
+
+	polyline := []*mat.VecDense{mat.NewVecDense(3, control[1])}
+	for segment := 1; segment < n; segment++ {
+		p := [4][]float64{control[segment-1], control[segment], control[segment+1], control[segment+2]}
+		var knots [4]float64
+		for index := 1; index < 4; index++ {
+			distance := Distance(mat.NewVecDense(3, p[index-1]), mat.NewVecDense(3, p[index]))
+			knots[index] = knots[index-1] + math.Sqrt(distance)
+		}
+		for sample := 1; sample <= samples; sample++ {
+			t := knots[1] + (knots[2]-knots[1])*float64(sample)/float64(samples)
+			polyline = append(polyline, mat.NewVecDense(3, catmullRomPoint(p, knots, t)))
+		}
+	}
+	return polyline, nil
+}
+
+// Equally spaced points along a polyline, the ends of the polyline excluded
+//
+// Args:
+// polyline ([]*mat.VecDense): the points of the polyline in order
+// count (int): number of points, dividing the polyline into count + 1 parts of the same length
+//
+//	Returns:
+//	[]*mat.VecDense: the points, in order
+func ResamplePolyline(polyline []*mat.VecDense, count int) []*mat.VecDense {
+	lengths := make([]float64, len(polyline))
+	for index := 1; index < len(polyline); index++ {
+		lengths[index] = lengths[index-1] + Distance(polyline[index-1], polyline[index])
+	}
+	total := lengths[len(lengths)-1]
+
+	resampled := make([]*mat.VecDense, 0, count)
+	segment := 1
+	for index := 1; index <= count; index++ {
+		target := total * float64(index) / float64(count+1)
+		for segment < len(polyline)-1 && lengths[segment] < target {
+			segment++
+		}
+		start, end := lengths[segment-1], lengths[segment]
+		ratio := 0.0
+		if end > start {
+			ratio = (target - start) / (end - start)
+		}
+		var point mat.VecDense
+		point.SubVec(polyline[segment], polyline[segment-1])
+		point.AddScaledVec(polyline[segment-1], ratio, &point)
+		resampled = append(resampled, &point)
+	}
+	return resampled
+}
+
+// Semilandmarks equally spaced on the spline through the control points, between the first and the last one
+func Semilandmarks(points []mat.Vector, count int) ([]*mat.VecDense, error) {
+	polyline, err := CatmullRom(points, CURVE_SAMPLES)
+	if err != nil {
+		return nil, err
+	}
+	return ResamplePolyline(polyline, count), nil
+}
@@ -0,0 +1,138 @@
+package photogrammetry
+
+import (
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// part of the image size added around the image when clipping the epipolar line,
+// the distortion can bring points from outside of the image inside
+const EPIPOLAR_MARGIN = 0.1
+
+// cameraCenter returns the center of the camera in world coordinates (-R^T * t)
+func cameraCenter(extrinsics mat.Matrix) *mat.VecDense {
+	extrinsicsDense := mat.DenseCopyOf(extrinsics)
+	var center mat.VecDense
+	center.MulVec(extrinsicsDense.Slice(0, 3, 0, 3).T(), extrinsicsDense.Slice(0, 3, 3, 4).(*mat.Dense).ColView(0))
+	center.ScaleVec(-1, &center)
+	return &center
+}
+
+// clipLine clips the points p + s * d (s in [0, sMax]) to the rectangle, ok is false if it doesn't cross it (Liang-Barsky)
+func clipLine(p [2]float64, d [2]float64, sMax float64, minX float64, minY float64, maxX float64, maxY float64) (float64, float64, bool) {
+	s0, s1 := 0.0, sMax
+	edges := [][2]float64{{-d[0], p[0] - minX}, {d[0], maxX - p[0]}, {-d[1], p[1] - minY}, {d[1], maxY - p[1]}}
+	for _, edge := range edges {
+		if edge[0] == 0 {
+			if edge[1] < 0 {
+				return 0, 0, false
+			}
+			continue
+		}
+		s := edge[1] / edge[0]
+		if edge[0] < 0 {
+			s0 = math.Max(s0, s)
+		} else {
+			s1 = math.Min(s1, s)
+		}
+	}
+	return s0, s1, s0 <= s1
+}
+
+// Epipolar curve of a pixel of image A in image B: the projection of the points of the viewing ray of the pixel
+// The ray projects as a line on the undistorted image, which is clipped to image B (with a margin), sampled
+// and distorted so the curve follows the lens distortion
+//
+// Args:
+// pose (mat.Vector): distorted pixel in image A
+// intrinsics (mat.Matrix): camera matrix
+// distCoeffs (mat.Matrix): distortion coefficients
+// extrinsicsA (mat.Matrix): extrinsics of image A
+// extrinsicsB (mat.Matrix): extrinsics of image B
+// width, height (float64): size of image B
+// step (float64): distance in pixels between two samples
+//
+//	Returns:
+//	[]Pos: distorted pixels of the curve in image B, empty if the ray isn't seen by camera B
+func EpipolarCurve(pose mat.Vector, intrinsics mat.Matrix, distCoeffs mat.Matrix, extrinsicsA mat.Matrix, extrinsicsB mat.Matrix, width float64, height float64, step float64) []Pos {
+	x, y := normalizePixel(UndistortIter(pose, intrinsics, distCoeffs), intrinsics)
+
+	// direction of the ray in world coordinates
+	rotationA := mat.DenseCopyOf(extrinsicsA).Slice(0, 3, 0, 3)
+	var direction mat.VecDense
+	direction.MulVec(rotationA.T(), mat.NewVecDense(3, []float64{x, y, 1}))
+
+	// the ray is (1 - t) * center + t * direction (at infinity) with t in [0, 1[,
+	// its projection is (1 - t) * epipole + t * vanishing point in homogeneous coordinates
+	projection := ProjectionMatrix(intrinsics, extrinsicsB)
+	center := cameraCenter(extrinsicsA)
+	var epipole, vanishing mat.VecDense
+	epipole.MulVec(projection, mat.NewVecDense(4, []float64{center.AtVec(0), center.AtVec(1), center.AtVec(2), 1}))
+	vanishing.MulVec(projection, mat.NewVecDense(4, []float64{direction.AtVec(0), direction.AtVec(1), direction.AtVec(2), 0}))
+
+	// the points behind camera B have a negative homogeneous coordinate
+	eW, vW := epipole.AtVec(2), vanishing.AtVec(2)
+	var start, delta [2]float64
+	sMax := 1.0
+	switch {
+	case eW > 0 && vW > 0:
+		start = [2]float64{epipole.AtVec(0) / eW, epipole.AtVec(1) / eW}
+		delta = [2]float64{vanishing.AtVec(0)/vW - start[0], vanishing.AtVec(1)/vW - start[1]}
+	case eW > 0 || vW > 0:
+		// the ray crosses the plane of camera B, its projection goes to infinity in the direction
+		// of the homogeneous point where w = 0
+		root := eW / (eW - vW)
+		if eW > 0 {
+			start = [2]float64{epipole.AtVec(0) / eW, epipole.AtVec(1) / eW}
+		} else {
+			start = [2]float64{vanishing.AtVec(0) / vW, vanishing.AtVec(1) / vW}
+		}
+		delta = [2]float64{
+			(1-root)*epipole.AtVec(0) + root*vanishing.AtVec(0),
+			(1-root)*epipole.AtVec(1) + root*vanishing.AtVec(1),
+		}
+		sMax = math.Inf(1)
+	default:
+		return []Pos{}
+	}
+
+	length := math.Hypot(delta[0], delta[1])
+	if length == 0 {
+		return []Pos{}
+	}
+	marginX, marginY := EPIPOLAR_MARGIN*width, EPIPOLAR_MARGIN*height
+	s0, s1, ok := clipLine(start, delta, sMax, -marginX, -marginY, width+marginX, height+marginY)
+	if !ok {
+		return []Pos{}
+	}
+
+	samples := int(math.Max(2, math.Ceil(length*(s1-s0)/step)+1))
+	curve := make([]Pos, 0, samples)
+	for index := 0; index < samples; index++ {
+		s := s0 + (s1-s0)*float64(index)/float64(samples-1)
+		undistorted := mat.NewVecDense(2, []float64{start[0] + s*delta[0], start[1] + s*delta[1]})
+		distorted := distort(undistorted, intrinsics, distCoeffs)
+		curve = append(curve, Pos{X: distorted.AtVec(0), Y: distorted.AtVec(1)})
+	}
+	return curve
+}
+
+// Distance from a pixel to the nearest segment of a polyline (an epipolar curve)
+func DistanceToPolyline(point Pos, polyline []Pos) float64 {
+	distance := math.Inf(1)
+	for index := range polyline {
+		a := polyline[index]
+		b := a
+		if index+1 < len(polyline) {
+			b = polyline[index+1]
+		}
+		dx, dy := b.X-a.X, b.Y-a.Y
+		t := 0.0
+		if length := dx*dx + dy*dy; length > 0 {
+			t = math.Max(0, math.Min(1, ((point.X-a.X)*dx+(point.Y-a.Y)*dy)/length))
+		}
+		distance = math.Min(distance, math.Hypot(point.X-a.X-t*dx, point.Y-a.Y-t*dy))
+	}
+	return distance
+}
@@ -0,0 +1,160 @@
+package photogrammetry
+
+import (
+	"errors"
+	"image"
+	"math"
+)
+
+var ErrNoFeature = errors.New("no feature strong enough around the pixel")
+
+// Harris response det(M) - HARRIS_K * trace(M)^2
+const HARRIS_K = 0.04
+
+// half size of the window the structure tensor is summed on
+const INTEGRATION_RADIUS = 2
+
+// smallest mean squared gradient (along the weakest direction) of a corner
+const MIN_CORNER_GRADIENT = 25
+
+// smallest difference of intensity between a blob and its surroundings
+const MIN_BLOB_CONTRAST = 20
+
+func grayAt(img *image.Gray, x int, y int) float64 {
+	return float64(img.Pix[img.PixOffset(x, y)])
+}
+
+// structureTensor sums the products of the gradients (central differences) around the pixel,
+// b is the sum of M_i * p_i used by the Förstner operator
+func structureTensor(img *image.Gray, center image.Point, radius int) (m [3]float64, b [2]float64, count int) {
+	inner := img.Bounds().Inset(1)
+	for y := center.Y - radius; y <= center.Y+radius; y++ {
+		for x := center.X - radius; x <= center.X+radius; x++ {
+			if !image.Pt(x, y).In(inner) {
+				continue
+			}
+			gx := (grayAt(img, x+1, y) - grayAt(img, x-1, y)) / 2
+			gy := (grayAt(img, x, y+1) - grayAt(img, x, y-1)) / 2
+			m[0] += gx * gx
+			m[1] += gx * gy
+			m[2] += gy * gy
+			b[0] += gx*gx*float64(x) + gx*gy*float64(y)
+			b[1] += gx*gy*float64(x) + gy*gy*float64(y)
+			count++
+		}
+	}
+	return m, b, count
+}
+
+// Corner near the pixel, at sub pixel precision
+// The pixel with the strongest Harris response within the radius is refined by the Förstner operator,
+// the point closest to the lines of all the gradients of its neighbourhood
+//
+// Args:
+// img (*image.Gray): full resolution image
+// pose (Pos): clicked pixel
+// radius (int): half size of the window searched
+//
+//	Returns:
+//	Pos: the corner
+//	float64: its roundness in [0, 1], 1 for an isotropic corner and 0 for an edge
+func ForstnerCorner(img *image.Gray, pose Pos, radius int) (Pos, float64, error) {
+	center := image.Pt(int(math.Round(pose.X)), int(math.Round(pose.Y)))
+
+	best := image.Point{}
+	bestResponse := math.Inf(-1)
+	for y := center.Y - radius; y <= center.Y+radius; y++ {
+		for x := center.X - radius; x <= center.X+radius; x++ {
+			m, _, count := structureTensor(img, image.Pt(x, y), INTEGRATION_RADIUS)
+			if count == 0 {
+				continue
+			}
+			response := m[0]*m[2] - m[1]*m[1] - HARRIS_K*(m[0]+m[2])*(m[0]+m[2])
+			if response > bestResponse {
+				best, bestResponse = image.Pt(x, y), response
+			}
+		}
+	}
+
+	m, b, count := structureTensor(img, best, INTEGRATION_RADIUS+1)
+	if count == 0 {
+		return pose, 0, ErrNoFeature
+	}
+	det := m[0]*m[2] - m[1]*m[1]
+	trace := m[0] + m[2]
+	weakest := trace/2 - math.Sqrt(math.Max(0, trace*trace/4-det))
+	if weakest/float64(count) < MIN_CORNER_GRADIENT {
+		return pose, 0, ErrNoFeature
+	}
+
+	corner := Pos{X: (m[2]*b[0] - m[1]*b[1]) / det, Y: (m[0]*b[1] - m[1]*b[0]) / det}
+	// the refinement stays within the neighbourhood used, otherwise it isn't a corner
+	if math.Abs(corner.X-float64(best.X)) > INTEGRATION_RADIUS+1 || math.Abs(corner.Y-float64(best.Y)) > INTEGRATION_RADIUS+1 {
+		corner = Pos{X: float64(best.X), Y: float64(best.Y)}
+	}
+	return corner, 4 * det / (trace * trace), nil
+}
+
+// Centroid of the blob (darker or lighter than its surroundings) under the pixel
+// The blob is the region connected to the pixel on the same side of the mean intensity of the window,
+// weighted by its difference to the mean
+//
+// Args:
+// img (*image.Gray): full resolution image
+// pose (Pos): clicked pixel
+// radius (int): half size of the window searched
+//
+//	Returns:
+//	Pos: the centroid
+//	float64: confidence in [0, 1], lowered by a weak contrast and by a blob running out of the window
+func BlobCentroid(img *image.Gray, pose Pos, radius int) (Pos, float64, error) {
+	center := image.Pt(int(math.Round(pose.X)), int(math.Round(pose.Y)))
+	window := image.Rect(center.X-radius, center.Y-radius, center.X+radius+1, center.Y+radius+1).Intersect(img.Bounds())
+	if !center.In(window) {
+		return pose, 0, ErrNoFeature
+	}
+
+	mean := 0.0
+	for y := window.Min.Y; y < window.Max.Y; y++ {
+		for x := window.Min.X; x < window.Max.X; x++ {
+			mean += grayAt(img, x, y)
+		}
+	}
+	mean /= float64(window.Dx() * window.Dy())
+
+	contrast := grayAt(img, center.X, center.Y) - mean
+	if math.Abs(contrast) < MIN_BLOB_CONTRAST {
+		return pose, 0, ErrNoFeature
+	}
+	sign := math.Copysign(1, contrast)
+
+	// flood fill from the pixel
+	visited := map[image.Point]bool{center: true}
+	queue := []image.Point{center}
+	sum, sumX, sumY := 0.0, 0.0, 0.0
+	border := false
+	for len(queue) > 0 {
+		point := queue[0]
+		queue = queue[1:]
+		weight := sign * (grayAt(img, point.X, point.Y) - mean)
+		sum += weight
+		sumX += weight * float64(point.X)
+		sumY += weight * float64(point.Y)
+		if point.X == window.Min.X || point.Y == window.Min.Y || point.X == window.Max.X-1 || point.Y == window.Max.Y-1 {
+			border = true
+		}
+		for _, next := range []image.Point{point.Add(image.Pt(1, 0)), point.Add(image.Pt(-1, 0)), point.Add(image.Pt(0, 1)), point.Add(image.Pt(0, -1))} {
+			if visited[next] || !next.In(window) || sign*(grayAt(img, next.X, next.Y)-mean) <= 0 {
+				continue
+			}
+			visited[next] = true
+			queue = append(queue, next)
+		}
+	}
+
+	confidence := math.Min(1, math.Abs(contrast)/(4*MIN_BLOB_CONTRAST))
+	if border {
+		confidence /= 2
+	}
+	return Pos{X: sumX / sum, Y: sumY / sum}, confidence, nil
+}
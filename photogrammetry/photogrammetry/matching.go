@@ -0,0 +1,116 @@
+package photogrammetry
+
+import (
+	"errors"
+	"image"
+	"math"
+)
+
+var ErrNoMatch = errors.New("no candidate could be compared with the template")
+
+// grayPatch returns the intensities of the square patch centered on the pixel (row by row),
+// ok is false if the patch isn't entirely inside the image
+func grayPatch(img *image.Gray, center image.Point, radius int) ([]float64, bool) {
+	bounds := image.Rect(center.X-radius, center.Y-radius, center.X+radius+1, center.Y+radius+1)
+	if !bounds.In(img.Bounds()) {
+		return nil, false
+	}
+	values := make([]float64, 0, bounds.Dx()*bounds.Dy())
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		row := img.Pix[img.PixOffset(bounds.Min.X, y):img.PixOffset(bounds.Max.X, y)]
+		for _, value := range row {
+			values = append(values, float64(value))
+		}
+	}
+	return values, true
+}
+
+// zeroMean centers the values on their mean and returns their norm
+func zeroMean(values []float64) float64 {
+	mean := 0.0
+	for _, value := range values {
+		mean += value
+	}
+	mean /= float64(len(values))
+	norm := 0.0
+	for index := range values {
+		values[index] -= mean
+		norm += values[index] * values[index]
+	}
+	return math.Sqrt(norm)
+}
+
+// Normalized cross correlation of two patches of the same size, in [-1, 1]
+// A flat patch (without texture) correlates with nothing and gives 0
+func NCC(a []float64, b []float64) float64 {
+	centeredA := append([]float64{}, a...)
+	centeredB := append([]float64{}, b...)
+	normA, normB := zeroMean(centeredA), zeroMean(centeredB)
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	dot := 0.0
+	for index := range centeredA {
+		dot += centeredA[index] * centeredB[index]
+	}
+	return dot / (normA * normB)
+}
+
+// parabolaPeak returns the offset (in [-0.5, 0.5]) of the top of the parabola through 3 equally spaced scores
+func parabolaPeak(before float64, peak float64, after float64) float64 {
+	denominator := before - 2*peak + after
+	if denominator >= 0 {
+		return 0
+	}
+	return math.Max(-0.5, math.Min(0.5, 0.5*(before-after)/denominator))
+}
+
+// Search the candidates of the target image for the patch around the pose in the reference image
+//
+// Args:
+// reference (*image.Gray): image where the pose is known
+// pose (Pos): pixel of the reference image
+// target (*image.Gray): image to search
+// candidates ([]image.Point): pixels of the target image to compare
+// radius (int): half size of the patches
+//
+//	Returns:
+//	Pos: best candidate, refined to sub pixel by fitting a parabola through the scores of its neighbours
+//	float64: normalized cross correlation of the best candidate
+func MatchTemplate(reference *image.Gray, pose Pos, target *image.Gray, candidates []image.Point, radius int) (Pos, float64, error) {
+	template, ok := grayPatch(reference, image.Pt(int(math.Round(pose.X)), int(math.Round(pose.Y))), radius)
+	if !ok {
+		return Pos{}, 0, ErrNoMatch
+	}
+
+	scores := make(map[image.Point]float64)
+	best := image.Point{}
+	bestScore := math.Inf(-1)
+	for _, candidate := range candidates {
+		values, ok := grayPatch(target, candidate, radius)
+		if !ok {
+			continue
+		}
+		score := NCC(template, values)
+		scores[candidate] = score
+		if score > bestScore {
+			best, bestScore = candidate, score
+		}
+	}
+	if len(scores) == 0 {
+		return Pos{}, 0, ErrNoMatch
+	}
+
+	refined := Pos{X: float64(best.X), Y: float64(best.Y)}
+	left, okLeft := scores[best.Add(image.Pt(-1, 0))]
+	right, okRight := scores[best.Add(image.Pt(1, 0))]
+	if okLeft && okRight {
+		refined.X += parabolaPeak(left, bestScore, right)
+	}
+	up, okUp := scores[best.Add(image.Pt(0, -1))]
+	down, okDown := scores[best.Add(image.Pt(0, 1))]
+	if okUp && okDown {
+		refined.Y += parabolaPeak(up, bestScore, down)
+	}
+	return refined, bestScore, nil
+}
@@ -0,0 +1,81 @@
+package photogrammetry
+
+import (
+	"errors"
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+var ErrDegenerateMeasure = errors.New("landmarks are at the same position or aligned, the measure is undefined")
+
+func sub3(a mat.Vector, b mat.Vector) *mat.VecDense {
+	var diff mat.VecDense
+	diff.SubVec(vec3(a), vec3(b))
+	return &diff
+}
+
+// angleBetween returns the angle (in radians) between two vectors
+func angleBetween(u mat.Vector, v mat.Vector) (float64, error) {
+	normU, normV := mat.Norm(u, 2), mat.Norm(v, 2)
+	if normU < frameEpsilon || normV < frameEpsilon {
+		return 0, ErrDegenerateMeasure
+	}
+	// atan2 keeps its precision for angles close to 0 and pi
+	return math.Atan2(mat.Norm(Cross(u, v), 2), mat.Dot(u, v)), nil
+}
+
+// Angle (in radians) at the vertex of the triangle a, vertex, c
+func Angle(a mat.Vector, vertex mat.Vector, c mat.Vector) (float64, error) {
+	return angleBetween(sub3(a, vertex), sub3(c, vertex))
+}
+
+// Dihedral angle (in radians, in ]-pi, pi]) between the planes (a, b, c) and (b, c, d) around the axis b-c
+func DihedralAngle(a mat.Vector, b mat.Vector, c mat.Vector, d mat.Vector) (float64, error) {
+	axis := sub3(c, b)
+	normal1 := Cross(sub3(b, a), axis)
+	normal2 := Cross(axis, sub3(d, c))
+	normAxis := mat.Norm(axis, 2)
+	if normAxis < frameEpsilon || mat.Norm(normal1, 2) < frameEpsilon || mat.Norm(normal2, 2) < frameEpsilon {
+		return 0, ErrDegenerateMeasure
+	}
+
+	var unitAxis mat.VecDense
+	unitAxis.ScaleVec(1/normAxis, axis)
+	y := mat.Dot(Cross(normal1, normal2), &unitAxis)
+	x := mat.Dot(normal1, normal2)
+	return math.Atan2(y, x), nil
+}
+
+// Length of the polyline through the points, in order
+func PolylineLength(points []mat.Vector) float64 {
+	length := 0.0
+	for index := 1; index < len(points); index++ {
+		length += Distance(points[index-1], points[index])
+	}
+	return length
+}
+
+// Area of the polygon whose vertices are the points, in order
+// The area of a non planar polygon is the area of its projection on the plane of its normal (Newell's method)
+func PolygonArea(points []mat.Vector) (float64, error) {
+	if len(points) < 3 {
+		return 0, ErrDegenerateMeasure
+	}
+	normal := mat.NewVecDense(3, nil)
+	for index := range points {
+		normal.AddVec(normal, Cross(points[index], points[(index+1)%len(points)]))
+	}
+	return mat.Norm(normal, 2) / 2, nil
+}
+
+// Signed distance of the point to the plane through a, b and c,
+// positive on the side of the normal (b - a) x (c - a)
+func PointPlaneDistance(point mat.Vector, a mat.Vector, b mat.Vector, c mat.Vector) (float64, error) {
+	normal := Cross(sub3(b, a), sub3(c, a))
+	norm := mat.Norm(normal, 2)
+	if norm < frameEpsilon {
+		return 0, ErrDegenerateMeasure
+	}
+	return mat.Dot(normal, sub3(point, a)) / norm, nil
+}
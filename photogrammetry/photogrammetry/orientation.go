@@ -0,0 +1,131 @@
+package photogrammetry
+
+import (
+	"errors"
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// The specimen frame is right handed :
+// X points to the anterior side, Y to the left side and Z to the superior side
+
+var ErrDegenerateFrame = errors.New("landmarks are aligned, cannot compute a reference frame")
+
+const frameEpsilon = 1e-12
+
+// Get the unit vector of geographic coordinates (in radians), inverse of GetLongLat
+func LongLatToVector(longitude float64, latitude float64) *mat.VecDense {
+	return mat.NewVecDense(3, []float64{
+		math.Cos(latitude) * math.Cos(longitude),
+		math.Cos(latitude) * math.Sin(longitude),
+		math.Sin(latitude),
+	})
+}
+
+// Rotation from the world frame to the specimen frame (v_specimen = R * v_world)
+// computed from an anterior, a posterior and a superior landmark
+//
+// Args:
+// anterior (mat.Vector): landmark on the anterior side
+// posterior (mat.Vector): landmark on the posterior side
+// superior (mat.Vector): landmark on the superior side
+//
+//	Returns:
+//	*mat.Dense: 3x3 rotation whose rows are the specimen axes in world coordinates
+func FrameFromLandmarks(anterior mat.Vector, posterior mat.Vector, superior mat.Vector) (*mat.Dense, error) {
+	var xAxis mat.VecDense
+	xAxis.SubVec(vec3(anterior), vec3(posterior))
+
+	var mid mat.VecDense
+	mid.AddVec(vec3(anterior), vec3(posterior))
+	mid.ScaleVec(0.5, &mid)
+
+	var zAxis mat.VecDense
+	zAxis.SubVec(vec3(superior), &mid)
+
+	return frameFromAxes(&xAxis, &zAxis)
+}
+
+// Rotation from the world frame to the specimen frame computed from the principal axes of the landmarks
+// The first axis (largest variance) is taken as the antero-posterior axis and the last one (smallest variance)
+// as the dorso-ventral axis, their signs are arbitrary and might need to be flipped by the user
+func FrameFromPCA(points []mat.Vector) (*mat.Dense, error) {
+	if len(points) < 3 {
+		return nil, ErrDegenerateFrame
+	}
+	data := mat.NewDense(len(points), 3, nil)
+	for index, point := range points {
+		data.SetRow(index, vec3(point).RawVector().Data)
+	}
+
+	var covariance mat.SymDense
+	centered := centerRows(data)
+	covariance.SymOuterK(1/float64(len(points)), centered.T())
+
+	var eigen mat.EigenSym
+	ok := eigen.Factorize(&covariance, true)
+	if !ok {
+		return nil, ErrDegenerateFrame
+	}
+	var vectors mat.Dense
+	eigen.VectorsTo(&vectors)
+	values := eigen.Values(nil)
+
+	// eigen values are in ascending order
+	if values[2]-values[0] < frameEpsilon {
+		return nil, ErrDegenerateFrame
+	}
+	xAxis := mat.VecDenseCopyOf(vectors.ColView(2))
+	zAxis := mat.VecDenseCopyOf(vectors.ColView(0))
+
+	return frameFromAxes(xAxis, zAxis)
+}
+
+func centerRows(data *mat.Dense) *mat.Dense {
+	rows, cols := data.Dims()
+	centered := mat.DenseCopyOf(data)
+	for col := 0; col < cols; col++ {
+		mean := mat.Sum(data.ColView(col)) / float64(rows)
+		for row := 0; row < rows; row++ {
+			centered.Set(row, col, data.At(row, col)-mean)
+		}
+	}
+	return centered
+}
+
+// frameFromAxes builds a rotation from the X axis and an approximate Z axis (orthogonalised)
+func frameFromAxes(xAxis *mat.VecDense, zAxis *mat.VecDense) (*mat.Dense, error) {
+	if xAxis.Norm(2) < frameEpsilon {
+		return nil, ErrDegenerateFrame
+	}
+	xAxis.ScaleVec(1/xAxis.Norm(2), xAxis)
+
+	// remove the component of Z along X
+	zAxis.AddScaledVec(zAxis, -mat.Dot(zAxis, xAxis), xAxis)
+	if zAxis.Norm(2) < frameEpsilon {
+		return nil, ErrDegenerateFrame
+	}
+	zAxis.ScaleVec(1/zAxis.Norm(2), zAxis)
+
+	yAxis := Cross(zAxis, xAxis)
+
+	rotation := mat.NewDense(3, 3, nil)
+	rotation.SetRow(0, xAxis.RawVector().Data)
+	rotation.SetRow(1, yAxis.RawVector().Data)
+	rotation.SetRow(2, zAxis.RawVector().Data)
+	return rotation, nil
+}
+
+// vec3 drops the homogeneous coordinate of a point
+func vec3(point mat.Vector) *mat.VecDense {
+	return mat.NewVecDense(3, []float64{point.AtVec(0), point.AtVec(1), point.AtVec(2)})
+}
+
+func Cross(u mat.Vector, v mat.Vector) *mat.VecDense {
+	return mat.NewVecDense(3, []float64{
+		u.AtVec(1)*v.AtVec(2) - u.AtVec(2)*v.AtVec(1),
+		u.AtVec(2)*v.AtVec(0) - u.AtVec(0)*v.AtVec(2),
+		u.AtVec(0)*v.AtVec(1) - u.AtVec(1)*v.AtVec(0),
+	})
+}
@@ -124,10 +124,14 @@ func distort(point mat.Vector, intrinsics mat.Matrix, distCoeffs mat.Matrix) mat
 
 func ProjectPoints(position mat.Vector, intrinsics mat.Matrix, extrinsics mat.Matrix, distCoeffs mat.Matrix) Pos {
 	var projMat mat.Dense
-	var point mat.Dense
 	projMat.Mul(intrinsics, extrinsics)
+	return ProjectWithMatrix(position, &projMat, intrinsics, distCoeffs)
+}
 
-	point.Mul(&projMat, position)
+// Same as ProjectPoints with the projection matrix (intrinsics * extrinsics) already computed
+func ProjectWithMatrix(position mat.Vector, projection mat.Matrix, intrinsics mat.Matrix, distCoeffs mat.Matrix) Pos {
+	var point mat.Dense
+	point.Mul(projection, position)
 
 	pointVec := mat.VecDenseCopyOf(scaleHomogeonousPoint(point.ColView(0)))
 
@@ -0,0 +1,51 @@
+package photogrammetry
+
+import (
+	"errors"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+var ErrNoScaleBar = errors.New("at least one scale bar with a non null length is needed")
+
+// Least squares scale factor between measured and known lengths of scale bars
+//
+// Args:
+// measured ([]float64): lengths of the bars in the project frame
+// known ([]float64): real lengths of the bars
+//
+//	Returns:
+//	float64: scale factor such as known ~ scale * measured
+//	[]float64: residual of every bar (scale * measured - known)
+func ScaleFromBars(measured []float64, known []float64) (float64, []float64, error) {
+	if len(measured) == 0 || len(measured) != len(known) {
+		return 0, nil, ErrNoScaleBar
+	}
+	vecMeasured := mat.NewVecDense(len(measured), measured)
+	vecKnown := mat.NewVecDense(len(known), known)
+
+	norm := mat.Dot(vecMeasured, vecMeasured)
+	if norm == 0 {
+		return 0, nil, ErrNoScaleBar
+	}
+	scale := mat.Dot(vecMeasured, vecKnown) / norm
+
+	var residuals mat.VecDense
+	residuals.ScaleVec(scale, vecMeasured)
+	residuals.SubVec(&residuals, vecKnown)
+	return scale, residuals.RawVector().Data, nil
+}
+
+// Uniform scaling around the origin of the frame
+func ScaleSimilarity(scale float64) Similarity {
+	similarity := IdentitySimilarity()
+	similarity.Scale = scale
+	return similarity
+}
+
+// Distance between two points, homogeneous coordinates are dropped
+func Distance(a mat.Vector, b mat.Vector) float64 {
+	var diff mat.VecDense
+	diff.SubVec(vec3(a), vec3(b))
+	return diff.Norm(2)
+}
@@ -0,0 +1,192 @@
+package photogrammetry
+
+import (
+	"errors"
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+var ErrNotEnoughPoints = errors.New("at least 3 non aligned points are needed")
+
+func IdentitySimilarity() Similarity {
+	return Similarity{
+		Scale:       1,
+		Rotation:    MatrixInfo{Shape: Shape{Row: 3, Col: 3}, Data: []float64{1, 0, 0, 0, 1, 0, 0, 0, 1}},
+		Translation: []float64{0, 0, 0},
+	}
+}
+
+func NewSimilarity(scale float64, rotation mat.Matrix, translation mat.Vector) Similarity {
+	return Similarity{
+		Scale:       scale,
+		Rotation:    MatrixInfo{Shape: Shape{Row: 3, Col: 3}, Data: mat.DenseCopyOf(rotation).RawMatrix().Data},
+		Translation: []float64{translation.AtVec(0), translation.AtVec(1), translation.AtVec(2)},
+	}
+}
+
+func (s Similarity) RotationMatrix() *mat.Dense {
+	return mat.NewDense(3, 3, s.Rotation.Data)
+}
+
+func (s Similarity) TranslationVector() *mat.VecDense {
+	return mat.NewVecDense(3, s.Translation)
+}
+
+// Apply the transform to a point, the homogeneous coordinate is dropped
+func (s Similarity) Apply(point mat.Vector) *mat.VecDense {
+	var transformed mat.VecDense
+	transformed.MulVec(s.RotationMatrix(), vec3(point))
+	transformed.ScaleVec(s.Scale, &transformed)
+	transformed.AddVec(&transformed, s.TranslationVector())
+	return &transformed
+}
+
+// Then returns the transform applying s then next
+func (s Similarity) Then(next Similarity) Similarity {
+	var rotation mat.Dense
+	rotation.Mul(next.RotationMatrix(), s.RotationMatrix())
+
+	// next.Scale * next.Rotation * s.Translation + next.Translation
+	translation := next.Apply(s.TranslationVector())
+
+	return NewSimilarity(next.Scale*s.Scale, &rotation, translation)
+}
+
+func (s Similarity) Inverse() Similarity {
+	rotation := s.RotationMatrix().T()
+
+	var translation mat.VecDense
+	translation.MulVec(rotation, s.TranslationVector())
+	translation.ScaleVec(-1/s.Scale, &translation)
+
+	return NewSimilarity(1/s.Scale, rotation, &translation)
+}
+
+// Express extrinsics in the transformed frame
+// With X' = sRX + t, the camera coordinates Rc * X + tc become (Rc * R^T * X' + s * tc - Rc * R^T * t) / s
+// and as the projection is homogeneous the factor 1/s can be dropped
+//
+// Args:
+// extrinsics (mat.Matrix): 3x4 (or 4x4) extrinsics [Rc | tc]
+// s (Similarity): the transform of the world frame
+//
+//	Returns:
+//	*mat.Dense: 3x4 extrinsics in the transformed frame
+func TransformExtrinsics(extrinsics mat.Matrix, s Similarity) *mat.Dense {
+	extrinsicsDense := mat.DenseCopyOf(extrinsics)
+	rotationCam := extrinsicsDense.Slice(0, 3, 0, 3)
+	transCam := mat.VecDenseCopyOf(extrinsicsDense.Slice(0, 3, 3, 4).(*mat.Dense).ColView(0))
+
+	var rotation mat.Dense
+	rotation.Mul(rotationCam, s.RotationMatrix().T())
+
+	var trans mat.VecDense
+	trans.MulVec(&rotation, s.TranslationVector())
+	trans.AddScaledVec(&trans, -s.Scale, transCam)
+	trans.ScaleVec(-1, &trans)
+
+	transformed := mat.NewDense(3, 4, nil)
+	transformed.Slice(0, 3, 0, 3).(*mat.Dense).Copy(&rotation)
+	transformed.SetCol(3, trans.RawVector().Data)
+	return transformed
+}
+
+// Least squares similarity transform from src to dst (Umeyama's method)
+//
+// Args:
+// src ([]mat.Vector): points in the current frame
+// dst ([]mat.Vector): the same points in the target frame
+//
+//	Returns:
+//	Similarity: transform such as dst ~ Scale * Rotation * src + Translation
+//	[]float64: residual distance of every point in the target frame
+func SimilarityFromPoints(src []mat.Vector, dst []mat.Vector) (Similarity, []float64, error) {
+	n := len(src)
+	if n < 3 || len(dst) != n {
+		return Similarity{}, nil, ErrNotEnoughPoints
+	}
+
+	srcMat := mat.NewDense(n, 3, nil)
+	dstMat := mat.NewDense(n, 3, nil)
+	for index := range src {
+		srcMat.SetRow(index, vec3(src[index]).RawVector().Data)
+		dstMat.SetRow(index, vec3(dst[index]).RawVector().Data)
+	}
+	srcMean := columnMeans(srcMat)
+	dstMean := columnMeans(dstMat)
+	srcCentered := centerRows(srcMat)
+	dstCentered := centerRows(dstMat)
+
+	var covariance mat.Dense
+	covariance.Mul(dstCentered.T(), srcCentered)
+	covariance.Scale(1/float64(n), &covariance)
+
+	var svd mat.SVD
+	ok := svd.Factorize(&covariance, mat.SVDFull)
+	if !ok {
+		return Similarity{}, nil, ErrNotEnoughPoints
+	}
+	var U, V mat.Dense
+	svd.UTo(&U)
+	svd.VTo(&V)
+	values := svd.Values(nil)
+
+	// two null singular values means the points are aligned
+	if values[1] < frameEpsilon {
+		return Similarity{}, nil, ErrNotEnoughPoints
+	}
+
+	// reflection guard
+	signs := []float64{1, 1, 1}
+	if mat.Det(&U)*mat.Det(&V) < 0 {
+		signs[2] = -1
+	}
+
+	var rotation mat.Dense
+	rotation.Mul(&U, mat.NewDiagDense(3, signs))
+	rotation.Mul(&rotation, V.T())
+
+	variance := 0.0
+	for index := 0; index < n; index++ {
+		row := srcCentered.RawRowView(index)
+		variance += (row[0]*row[0] + row[1]*row[1] + row[2]*row[2]) / float64(n)
+	}
+	scale := (values[0]*signs[0] + values[1]*signs[1] + values[2]*signs[2]) / variance
+
+	var translation mat.VecDense
+	translation.MulVec(&rotation, srcMean)
+	translation.ScaleVec(-scale, &translation)
+	translation.AddVec(&translation, dstMean)
+
+	similarity := NewSimilarity(scale, &rotation, &translation)
+
+	residuals := make([]float64, n)
+	for index := range src {
+		var diff mat.VecDense
+		diff.SubVec(similarity.Apply(src[index]), vec3(dst[index]))
+		residuals[index] = diff.Norm(2)
+	}
+	return similarity, residuals, nil
+}
+
+// Root mean square of the residuals
+func RMS(residuals []float64) float64 {
+	if len(residuals) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, residual := range residuals {
+		sum += residual * residual
+	}
+	return math.Sqrt(sum / float64(len(residuals)))
+}
+
+func columnMeans(data *mat.Dense) *mat.VecDense {
+	rows, cols := data.Dims()
+	means := mat.NewVecDense(cols, nil)
+	for col := 0; col < cols; col++ {
+		means.SetVec(col, mat.Sum(data.ColView(col))/float64(rows))
+	}
+	return means
+}
@@ -27,6 +27,13 @@ type Intrinsics struct {
 	DistortionMatrix MatrixInfo
 }
 
+// 7 parameters similarity (Helmert) transform : X' = Scale * Rotation * X + Translation
+type Similarity struct {
+	Scale       float64    `json:"scale"`
+	Rotation    MatrixInfo `json:"rotation"`
+	Translation []float64  `json:"translation"`
+}
+
 type Coordinates struct {
 	Longitude float64 `json:"longitude"`
 	Latitude  float64 `json:"latitude"`
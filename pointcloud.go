@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+
+	"gonum.org/v1/gonum/mat"
+	sph "sphaeroptica.be/photogrammetry/photogrammetry"
+)
+
+// Landmarks (and cameras) as point clouds for MeshLab or CloudCompare
+
+// length of the viewing directions, relative to the mean distance of the cameras to their centroid
+const CAMERA_DIRECTION_LENGTH = 0.2
+
+var CAMERA_COLOR = []uint8{128, 128, 128}
+
+type cloudVertex struct {
+	Name  string
+	X     float64
+	Y     float64
+	Z     float64
+	Color []uint8
+}
+
+type cloudEdge struct {
+	A int
+	B int
+}
+
+// findLandmark finds a landmark by its key or by its label
+func findLandmark(landmarks ExportJSON, reference string) (LandmarkJSON, bool) {
+	if landmark, ok := landmarks.Landmarks[reference]; ok {
+		return landmark, true
+	}
+	for _, landmark := range landmarks.Landmarks {
+		if landmark.Label == reference {
+			return landmark, true
+		}
+	}
+	return LandmarkJSON{}, false
+}
+
+// buildPointCloud returns the triangulated landmarks with the distances as edges,
+// and optionally the camera centers with their viewing directions
+func buildPointCloud(export LandmarksExport, cameras bool) ([]cloudVertex, []cloudEdge) {
+	vertices := make([]cloudVertex, 0)
+	edges := make([]cloudEdge, 0)
+
+	indices := make(map[string]int)
+	for _, landmark := range orderedLandmarks(export.Landmarks) {
+		if len(landmark.Position) < 3 {
+			continue
+		}
+		color := []uint8{255, 0, 0}
+		if rgb, err := parseColor(landmark.Color); err == nil {
+			color = []uint8{uint8(rgb[0]*255 + 0.5), uint8(rgb[1]*255 + 0.5), uint8(rgb[2]*255 + 0.5)}
+		}
+		indices[landmark.Label] = len(vertices)
+		vertices = append(vertices, cloudVertex{Name: landmark.Label, X: landmark.Position[0], Y: landmark.Position[1], Z: landmark.Position[2], Color: color})
+	}
+	addEdge := func(left string, right string) {
+		leftLandmark, okLeft := findLandmark(export.Landmarks, left)
+		rightLandmark, okRight := findLandmark(export.Landmarks, right)
+		if !okLeft || !okRight {
+			return
+		}
+		a, okA := indices[leftLandmark.Label]
+		b, okB := indices[rightLandmark.Label]
+		if okA && okB {
+			edges = append(edges, cloudEdge{A: a, B: b})
+		}
+	}
+	for _, distance := range export.Landmarks.Distances {
+		addEdge(distance.Left, distance.Right)
+	}
+	// polylines and polygons are drawn as well
+	for _, measure := range export.Landmarks.Measures {
+		if measure.Type != MEASURE_POLYLINE && measure.Type != MEASURE_AREA {
+			continue
+		}
+		for index := 1; index < len(measure.Landmarks); index++ {
+			addEdge(measure.Landmarks[index-1], measure.Landmarks[index])
+		}
+		if measure.Type == MEASURE_AREA && len(measure.Landmarks) > 2 {
+			addEdge(measure.Landmarks[len(measure.Landmarks)-1], measure.Landmarks[0])
+		}
+	}
+
+	if !cameras || export.Project == nil {
+		return vertices, edges
+	}
+
+	images := make([]string, 0, len(export.Project.Extrinsics))
+	for image := range export.Project.Extrinsics {
+		images = append(images, image)
+	}
+	sort.Strings(images)
+
+	centers := make([]mat.Vector, len(images))
+	directions := make([]mat.Vector, len(images))
+	var centroid mat.VecDense
+	centroid.ReuseAsVec(3)
+	for index, image := range images {
+		extrinsics := export.Project.extrinsics(image)
+		rotation := mat.DenseCopyOf(extrinsics.Slice(0, 3, 0, 3))
+		trans := mat.DenseCopyOf(extrinsics.Slice(0, 3, 3, 4))
+		centers[index] = sph.GetCameraWorldsCoordinates(rotation, trans)
+		// the camera looks along its Z axis
+		directions[index] = rotation.RowView(2)
+		centroid.AddVec(&centroid, centers[index])
+	}
+	if len(images) == 0 {
+		return vertices, edges
+	}
+	centroid.ScaleVec(1/float64(len(images)), &centroid)
+
+	length := 0.0
+	for _, center := range centers {
+		length += sph.Distance(center, &centroid)
+	}
+	length = CAMERA_DIRECTION_LENGTH * length / float64(len(images))
+
+	for index, image := range images {
+		var target mat.VecDense
+		target.AddScaledVec(centers[index], length/mat.Norm(directions[index], 2), directions[index])
+
+		edges = append(edges, cloudEdge{A: len(vertices), B: len(vertices) + 1})
+		vertices = append(vertices,
+			cloudVertex{Name: image, X: centers[index].AtVec(0), Y: centers[index].AtVec(1), Z: centers[index].AtVec(2), Color: CAMERA_COLOR},
+			cloudVertex{Name: image + " direction", X: target.AtVec(0), Y: target.AtVec(1), Z: target.AtVec(2), Color: CAMERA_COLOR},
+		)
+	}
+	return vertices, edges
+}
+
+func writePLY(w io.Writer, export LandmarksExport, cameras bool) error {
+	vertices, edges := buildPointCloud(export, cameras)
+
+	buffer := bufio.NewWriter(w)
+	fmt.Fprintf(buffer, "ply\nformat ascii 1.0\ncomment Sphaeroptica landmarks of %s\n", export.Specimen)
+	fmt.Fprintf(buffer, "element vertex %d\n", len(vertices))
+	fmt.Fprintf(buffer, "property double x\nproperty double y\nproperty double z\n")
+	fmt.Fprintf(buffer, "property uchar red\nproperty uchar green\nproperty uchar blue\n")
+	fmt.Fprintf(buffer, "element edge %d\n", len(edges))
+	fmt.Fprintf(buffer, "property int vertex1\nproperty int vertex2\n")
+	fmt.Fprintf(buffer, "end_header\n")
+	for _, vertex := range vertices {
+		fmt.Fprintf(buffer, "%s %s %s %d %d %d\n", formatCoordinate(vertex.X), formatCoordinate(vertex.Y), formatCoordinate(vertex.Z), vertex.Color[0], vertex.Color[1], vertex.Color[2])
+	}
+	for _, edge := range edges {
+		fmt.Fprintf(buffer, "%d %d\n", edge.A, edge.B)
+	}
+	return buffer.Flush()
+}
+
+// writeOBJ writes the vertices with their colors (extension read by MeshLab and CloudCompare) and the edges as lines
+func writeOBJ(w io.Writer, export LandmarksExport, cameras bool) error {
+	vertices, edges := buildPointCloud(export, cameras)
+
+	buffer := bufio.NewWriter(w)
+	fmt.Fprintf(buffer, "# Sphaeroptica landmarks of %s\n", export.Specimen)
+	for _, vertex := range vertices {
+		fmt.Fprintf(buffer, "# %s\n", vertex.Name)
+		fmt.Fprintf(buffer, "v %s %s %s %s %s %s\n", formatCoordinate(vertex.X), formatCoordinate(vertex.Y), formatCoordinate(vertex.Z),
+			formatCoordinate(float64(vertex.Color[0])/255), formatCoordinate(float64(vertex.Color[1])/255), formatCoordinate(float64(vertex.Color[2])/255))
+	}
+	for _, edge := range edges {
+		// OBJ indices start at 1
+		fmt.Fprintf(buffer, "l %d %d\n", edge.A+1, edge.B+1)
+	}
+	return buffer.Flush()
+}
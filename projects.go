@@ -0,0 +1,158 @@
+package main
+
+import (
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// Projects open side by side, by absolute path
+// Every binding locks the project it works on, so parallel calls can't change it under each other
+
+type openProject struct {
+	mutex   sync.Mutex
+	project *project
+	// set under the mutex once the project is closed, the calls waiting for it find it closed
+	closed bool
+}
+
+type registry struct {
+	mutex    sync.Mutex
+	projects map[string]*openProject
+}
+
+func newRegistry() *registry {
+	return &registry{projects: make(map[string]*openProject)}
+}
+
+// projectKey returns the path the project is registered with
+func projectKey(projectFile string) string {
+	path, err := filepath.Abs(projectFile)
+	if err != nil {
+		return filepath.Clean(projectFile)
+	}
+	return path
+}
+
+// get returns the project if it is open
+func (r *registry) get(projectFile string) (*openProject, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	opened, ok := r.projects[projectKey(projectFile)]
+	return opened, ok
+}
+
+// open returns the project, read from its file if it isn't open yet
+func (r *registry) open(projectFile string) (*openProject, error) {
+	if opened, ok := r.get(projectFile); ok {
+		return opened, nil
+	}
+
+	// the file is read without blocking the other projects
+	calibFile, err := readProjectFile(projectFile)
+	if err != nil {
+		return nil, err
+	}
+	calibFile.matrices()
+
+	key := projectKey(projectFile)
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	// opened by a parallel call in the meantime
+	if opened, ok := r.projects[key]; ok {
+		return opened, nil
+	}
+	opened := &openProject{project: calibFile}
+	r.projects[key] = opened
+	return opened, nil
+}
+
+// add opens a project just created, in place of the one open with the same path
+func (r *registry) add(projectFile string, calibFile *project) {
+	key := projectKey(projectFile)
+	r.mutex.Lock()
+	previous, ok := r.projects[key]
+	r.projects[key] = &openProject{project: calibFile}
+	r.mutex.Unlock()
+	if ok {
+		previous.close()
+	}
+}
+
+// close forgets the project once the calls working on it are done,
+// the calls waiting for it read it again from its file
+func (r *registry) close(projectFile string) bool {
+	opened, ok := r.get(projectFile)
+	if !ok {
+		return false
+	}
+	opened.mutex.Lock()
+	defer opened.mutex.Unlock()
+	if opened.closed {
+		return false
+	}
+	opened.closed = true
+
+	key := projectKey(projectFile)
+	r.mutex.Lock()
+	if r.projects[key] == opened {
+		delete(r.projects, key)
+	}
+	r.mutex.Unlock()
+	return true
+}
+
+func (o *openProject) close() {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.closed = true
+}
+
+func (r *registry) paths() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	paths := make([]string, 0, len(r.projects))
+	for path := range r.projects {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// acquire locks the project, opened if it isn't yet,
+// and release has to be called once done with it
+func (a *App) acquire(projectFile string) (p *project, release func(), err error) {
+	for {
+		opened, err := a.projects.open(projectFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		opened.mutex.Lock()
+		if !opened.closed {
+			return opened.project, opened.mutex.Unlock, nil
+		}
+		// closed while waiting for it, it is already out of the registry
+		opened.mutex.Unlock()
+	}
+}
+
+// Open a project next to the ones already open, the other calls open it as well if needed
+func (a *App) OpenProject(projectFile string) error {
+	if _, err := a.projects.open(projectFile); err != nil {
+		return newError(ERROR_PROJECT, err)
+	}
+	return nil
+}
+
+// Close a project once the calls working on it are done, its changes are already saved
+func (a *App) CloseProject(projectFile string) error {
+	if !a.projects.close(projectFile) {
+		return errorf(ERROR_PROJECT, "project %s isn't open", projectFile)
+	}
+	return nil
+}
+
+// Get the paths of the open projects
+func (a *App) OpenProjects() []string {
+	return a.projects.paths()
+}
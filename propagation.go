@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"log"
+	"math"
+	"sort"
+
+	"gonum.org/v1/gonum/mat"
+	sph "sphaeroptica.be/photogrammetry/photogrammetry"
+)
+
+// Propagation of a landmark to the neighbouring views by template matching on the full resolution images
+
+// number of views the landmark is searched on
+const PROPAGATION_VIEWS = 6
+
+// half size (in pixels) of the patches compared
+const PATCH_RADIUS = 10
+
+// half size (in pixels) of the window searched around the reprojection
+const SEARCH_RADIUS = 40
+
+// distance (in pixels) to the epipolar curve of the reference pose a match can have
+const EPIPOLAR_TOLERANCE = 2
+
+// normalized cross correlation under which a match is rejected
+const MIN_CORRELATION = 0.8
+
+// nearestReference returns the image of the poses whose camera is the nearest to the camera of the image
+func (p *project) nearestReference(image string, poses map[string]sph.Pos) string {
+	nearest := ""
+	distance := math.Inf(1)
+	for reference := range poses {
+		d := sph.Distance(p.cameraCenter(image), p.cameraCenter(reference))
+		if d < distance || (d == distance && reference < nearest) {
+			nearest, distance = reference, d
+		}
+	}
+	return nearest
+}
+
+// propagationViews returns the images without pose where the point is visible,
+// sorted by the distance of their camera to the nearest camera with a pose
+func (p *project) propagationViews(position []float64, poses map[string]sph.Pos) []string {
+	distances := make(map[string]float64)
+	views := make([]string, 0)
+	for image := range p.Extrinsics {
+		if _, ok := poses[image]; ok {
+			continue
+		}
+		if _, visible := p.reprojectVisible(image, position); !visible {
+			continue
+		}
+		distances[image] = sph.Distance(p.cameraCenter(image), p.cameraCenter(p.nearestReference(image, poses)))
+		views = append(views, image)
+	}
+	sort.Slice(views, func(i, j int) bool {
+		if distances[views[i]] == distances[views[j]] {
+			return views[i] < views[j]
+		}
+		return distances[views[i]] < distances[views[j]]
+	})
+	if len(views) > PROPAGATION_VIEWS {
+		views = views[:PROPAGATION_VIEWS]
+	}
+	return views
+}
+
+// epipolarCandidates returns the pixels of the search window around the reprojection close to the epipolar curve
+func epipolarCandidates(center sph.Pos, curve []sph.Pos) []image.Point {
+	// only the part of the curve crossing the window
+	near := make([]sph.Pos, 0)
+	for _, point := range curve {
+		if math.Abs(point.X-center.X) <= SEARCH_RADIUS+EPIPOLAR_STEP && math.Abs(point.Y-center.Y) <= SEARCH_RADIUS+EPIPOLAR_STEP {
+			near = append(near, point)
+		}
+	}
+	candidates := make([]image.Point, 0)
+	if len(near) == 0 {
+		return candidates
+	}
+
+	x0, y0 := int(math.Round(center.X)), int(math.Round(center.Y))
+	for y := y0 - SEARCH_RADIUS; y <= y0+SEARCH_RADIUS; y++ {
+		for x := x0 - SEARCH_RADIUS; x <= x0+SEARCH_RADIUS; x++ {
+			if sph.DistanceToPolyline(sph.Pos{X: float64(x), Y: float64(y)}, near) <= EPIPOLAR_TOLERANCE {
+				candidates = append(candidates, image.Pt(x, y))
+			}
+		}
+	}
+	return candidates
+}
+
+// propagationSearch is a view searched for the landmark, prepared under the project lock
+type propagationSearch struct {
+	view       string
+	reference  string
+	candidates []image.Point
+}
+
+// Search the landmark placed on at least 2 images on the neighbouring views,
+// and triangulate it again with the poses found.
+// The full images are decoded and compared without the project lock
+func (a *App) PropagateLandmark(projectFile string, poses map[string]sph.Pos) (Propagation, error) {
+	result := Propagation{Position: []float64{}, Poses: poses, Matches: []Match{}}
+	p, release, err := a.acquire(projectFile)
+	if err != nil {
+		return result, newError(ERROR_PROJECT, err)
+	}
+
+	position, err := p.triangulate(poses)
+	if err != nil {
+		release()
+		return result, err
+	}
+	result.Position = position
+
+	matrices := p.matrices()
+	searches := make([]propagationSearch, 0, PROPAGATION_VIEWS)
+	for _, view := range p.propagationViews(position, poses) {
+		reprojected, _ := p.reprojectVisible(view, position)
+		reference := p.nearestReference(view, poses)
+
+		curve := sph.EpipolarCurve(
+			mat.NewVecDense(2, []float64{poses[reference].X, poses[reference].Y}),
+			matrices.intrinsics, matrices.distCoeffs,
+			matrices.extrinsics[reference], matrices.extrinsics[view],
+			float64(p.Intrinsics.Width), float64(p.Intrinsics.Height),
+			EPIPOLAR_STEP,
+		)
+		candidates := epipolarCandidates(reprojected, curve)
+		if len(candidates) == 0 {
+			continue
+		}
+		searches = append(searches, propagationSearch{view: view, reference: reference, candidates: candidates})
+	}
+	release()
+
+	matched := make(map[string]sph.Pos)
+	for image, pose := range poses {
+		matched[image] = pose
+	}
+	for _, search := range searches {
+		// a full image missing (archive shared with the thumbnails only) skips the view, not the propagation
+		referenceImage, err := fullImage(projectFile, search.reference)
+		if err != nil {
+			log.Printf("%s : %v\n", search.reference, err)
+			result.Matches = append(result.Matches, Match{Image: search.view, Error: fmt.Sprintf("reference %s : %v", search.reference, err)})
+			continue
+		}
+		viewImage, err := fullImage(projectFile, search.view)
+		if err != nil {
+			log.Printf("%s : %v\n", search.view, err)
+			result.Matches = append(result.Matches, Match{Image: search.view, Error: err.Error()})
+			continue
+		}
+
+		pose, score, err := sph.MatchTemplate(referenceImage, poses[search.reference], viewImage, search.candidates, PATCH_RADIUS)
+		if err != nil || score < MIN_CORRELATION {
+			continue
+		}
+		matched[search.view] = pose
+		result.Matches = append(result.Matches, Match{Image: search.view, Pose: pose, Score: score})
+	}
+
+	if len(matched) == len(poses) {
+		return result, nil
+	}
+	p, release, err = a.acquire(projectFile)
+	if err != nil {
+		return result, newError(ERROR_PROJECT, err)
+	}
+	defer release()
+	result.Position, err = p.triangulate(matched)
+	if err != nil {
+		return result, err
+	}
+	result.Poses = matched
+	return result, nil
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// Reference images of the protocol in the archives
+const PROTOCOL_DIR = "protocol"
+
+// readProtocol reads a protocol file to attach to the project, the reference images (relative to the file)
+// are stored relative to the project so they still resolve once the project is shared with them.
+// They stay absolute for an archive, they are packed into the archives it is exported to
+func readProtocol(file string, projectFile string) (*Protocol, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var protocol Protocol
+	if err := json.Unmarshal(data, &protocol); err != nil {
+		return nil, err
+	}
+	if protocol.Name == "" {
+		protocol.Name = strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	}
+	protocolDir, _ := filepath.Abs(filepath.Dir(file))
+	projectDir, _ := filepath.Abs(filepath.Dir(projectFile))
+	for index, landmark := range protocol.Landmarks {
+		if landmark.Image == "" {
+			continue
+		}
+		image := filepath.FromSlash(landmark.Image)
+		if !filepath.IsAbs(image) {
+			image = filepath.Join(protocolDir, image)
+		}
+		// images on another volume stay absolute
+		if relative, err := filepath.Rel(projectDir, image); err == nil && !isArchive(projectFile) {
+			image = relative
+		}
+		protocol.Landmarks[index].Image = filepath.ToSlash(image)
+	}
+	if err := protocol.check(); err != nil {
+		return nil, fmt.Errorf("%s : %w", filepath.Base(file), err)
+	}
+	return &protocol, nil
+}
+
+func (p *Protocol) check() error {
+	if len(p.Landmarks) == 0 {
+		return fmt.Errorf("the protocol has no landmark")
+	}
+	names := make(map[string]bool)
+	for _, landmark := range p.Landmarks {
+		if landmark.Name == "" {
+			return fmt.Errorf("a landmark has no name")
+		}
+		if names[landmark.Name] {
+			return fmt.Errorf("landmark %s is defined twice", landmark.Name)
+		}
+		names[landmark.Name] = true
+		if landmark.Color != "" {
+			if _, err := parseColor(landmark.Color); err != nil {
+				return fmt.Errorf("landmark %s : %w", landmark.Name, err)
+			}
+		}
+	}
+	for _, curve := range p.Curves {
+		if !names[curve.Start] || !names[curve.End] {
+			return fmt.Errorf("curve %s : %s and %s must be landmarks of the protocol", curve.Name, curve.Start, curve.End)
+		}
+		if curve.Semilandmarks < 1 {
+			return fmt.Errorf("curve %s : at least one semilandmark is needed", curve.Name)
+		}
+	}
+	return nil
+}
+
+// resolved returns the protocol with its reference images resolved against the project directory,
+// or against the archive they are packed into
+func (p *Protocol) resolved(projectFile string) Protocol {
+	protocol := *p
+	protocol.Landmarks = slices.Clone(p.Landmarks)
+	for index, landmark := range protocol.Landmarks {
+		image := filepath.FromSlash(landmark.Image)
+		if image == "" || filepath.IsAbs(image) {
+			continue
+		}
+		if isArchive(projectFile) {
+			protocol.Landmarks[index].Image = fmt.Sprintf("%s/%s", projectRoot(projectFile), landmark.Image)
+		} else {
+			protocol.Landmarks[index].Image = filepath.Join(filepath.Dir(projectFile), image)
+		}
+	}
+	return protocol
+}
+
+// archived returns the protocol with its reference images as entries of an archive (protocol/<image>),
+// and the files they are read from by entry
+func (p *Protocol) archived(projectFile string) (*Protocol, map[string]string) {
+	protocol := p.resolved(projectFile)
+	files := make(map[string]string)
+	entries := make(map[string]string)
+	for index, landmark := range protocol.Landmarks {
+		if landmark.Image == "" {
+			continue
+		}
+		entry, ok := entries[landmark.Image]
+		if !ok {
+			name := path.Base(filepath.ToSlash(landmark.Image))
+			entry = path.Join(PROTOCOL_DIR, name)
+			// two images with the same name in different directories
+			if _, taken := files[entry]; taken {
+				entry = path.Join(PROTOCOL_DIR, fmt.Sprintf("%d_%s", index, name))
+			}
+			entries[landmark.Image] = entry
+			files[entry] = landmark.Image
+		}
+		protocol.Landmarks[index].Image = entry
+	}
+	return &protocol, files
+}
+
+// names returns the landmarks of the protocol in order
+func (p *Protocol) names() []string {
+	names := make([]string, len(p.Landmarks))
+	for index, landmark := range p.Landmarks {
+		names[index] = landmark.Name
+	}
+	return names
+}
+
+// validate checks the landmarks of a session against the protocol,
+// a landmark is missing if it isn't placed or not triangulated.
+// The landmarks clicked along the curves aren't in the protocol, they aren't unknown
+func (p *Protocol) validate(landmarks ExportJSON) ProtocolValidation {
+	validation := ProtocolValidation{
+		MissingRequired:  []string{},
+		MissingOptional:  []string{},
+		Unknown:          []string{},
+		UnexpectedColors: []string{},
+	}
+
+	byLabel := make(map[string]LandmarkJSON)
+	for _, landmark := range landmarks.Landmarks {
+		byLabel[landmark.Label] = landmark
+	}
+
+	known := make(map[string]bool)
+	for _, expected := range p.Landmarks {
+		known[expected.Name] = true
+		landmark, ok := byLabel[expected.Name]
+		if !ok || len(landmark.Position) < 3 {
+			if expected.Required {
+				validation.MissingRequired = append(validation.MissingRequired, expected.Name)
+			} else {
+				validation.MissingOptional = append(validation.MissingOptional, expected.Name)
+			}
+		}
+		if ok && expected.Color != "" && !strings.EqualFold(landmark.Color, expected.Color) {
+			validation.UnexpectedColors = append(validation.UnexpectedColors, expected.Name)
+		}
+	}
+	alongCurves := curvePoints(landmarks)
+	for label := range byLabel {
+		if !known[label] && !alongCurves[label] {
+			validation.Unknown = append(validation.Unknown, label)
+		}
+	}
+	sort.Strings(validation.Unknown)
+
+	validation.Valid = len(validation.MissingRequired) == 0 && len(validation.Unknown) == 0
+	return validation
+}
+
+// protocol returns the protocol of the project, nil without project
+func (p *project) protocol() *Protocol {
+	if p == nil {
+		return nil
+	}
+	return p.Protocol
+}
+
+// Get the landmark protocol of the project, empty if it has none
+func (a *App) Protocol(projectFile string) (Protocol, error) {
+	p, release, err := a.acquire(projectFile)
+	if err != nil {
+		return Protocol{}, newError(ERROR_PROJECT, err)
+	}
+	defer release()
+
+	if p.Protocol == nil {
+		return Protocol{}, nil
+	}
+	return p.Protocol.resolved(projectFile), nil
+}
+
+// Load a protocol file and attach it to the project
+func (a *App) ImportProtocol(projectFile string) (Protocol, error) {
+	file := a.openFileDialog("Select Protocol File", []runtime.FileFilter{
+		{
+			DisplayName: "Landmark protocol (*.json)",
+			Pattern:     "*.json",
+		},
+	})
+	if file == "" {
+		return Protocol{}, nil
+	}
+	protocol, err := readProtocol(file, projectFile)
+	if err != nil {
+		return Protocol{}, newError(ERROR_FILE, err)
+	}
+
+	p, release, err := a.acquire(projectFile)
+	if err != nil {
+		return Protocol{}, newError(ERROR_PROJECT, err)
+	}
+	defer release()
+
+	p.Protocol = protocol
+	err = saveProjectFile(projectFile, p)
+	if err != nil {
+		return Protocol{}, newError(ERROR_PROJECT, err)
+	}
+	return protocol.resolved(projectFile), nil
+}
+
+// Detach the protocol from the project
+func (a *App) RemoveProtocol(projectFile string) error {
+	p, release, err := a.acquire(projectFile)
+	if err != nil {
+		return newError(ERROR_PROJECT, err)
+	}
+	defer release()
+
+	p.Protocol = nil
+	err = saveProjectFile(projectFile, p)
+	if err != nil {
+		return newError(ERROR_PROJECT, err)
+	}
+	return nil
+}
+
+// Check the landmarks of a session against the protocol of the project
+func (a *App) ValidateSession(projectFile string, landmarks ExportJSON) (ProtocolValidation, error) {
+	p, release, err := a.acquire(projectFile)
+	if err != nil {
+		return ProtocolValidation{}, newError(ERROR_PROJECT, err)
+	}
+	defer release()
+
+	if p.Protocol == nil {
+		return ProtocolValidation{Valid: true}, nil
+	}
+	return p.Protocol.validate(landmarks), nil
+}
@@ -0,0 +1,352 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/gonum/stat"
+	morph "sphaeroptica.be/morphometrics/morphometrics"
+	sph "sphaeroptica.be/photogrammetry/photogrammetry"
+)
+
+// sessionDistances returns the length of every distance of the sessions, by label and by session,
+// in the order the distances are first found
+func sessionDistances(names []string, sessions map[string]ExportJSON) ([]string, map[string]map[string]float64) {
+	labels := make([]string, 0)
+	lengths := make(map[string]map[string]float64)
+	ends := make(map[string][2]string)
+	for _, name := range names {
+		for _, distance := range sessions[name].Distances {
+			if _, ok := ends[distance.Label]; ok {
+				continue
+			}
+			left, okLeft := findLandmark(sessions[name], distance.Left)
+			right, okRight := findLandmark(sessions[name], distance.Right)
+			if !okLeft || !okRight {
+				continue
+			}
+			ends[distance.Label] = [2]string{left.Label, right.Label}
+			labels = append(labels, distance.Label)
+			lengths[distance.Label] = make(map[string]float64)
+		}
+	}
+
+	for _, name := range names {
+		positions := landmarkPositions(sessions[name])
+		for _, label := range labels {
+			left, okLeft := positions[ends[label][0]]
+			right, okRight := positions[ends[label][1]]
+			if okLeft && okRight {
+				lengths[label][name] = sph.Distance(mat.NewVecDense(3, left[:3]), mat.NewVecDense(3, right[:3]))
+			}
+		}
+	}
+	return labels, lengths
+}
+
+// measurementError compares the landmarks and distances of several sessions of the same specimen,
+// the repeatability (ICC) of the distances needs several specimens (distanceRepeatability)
+func measurementError(names []string, sessions map[string]ExportJSON) (MeasurementErrorReport, error) {
+	if len(names) < 2 {
+		return MeasurementErrorReport{}, fmt.Errorf("at least 2 sessions are needed, got %d", len(names))
+	}
+	report := MeasurementErrorReport{Sessions: names, Landmarks: []LandmarkError{}, Distances: []DistanceError{}}
+
+	labels := make([]string, 0)
+	known := make(map[string]bool)
+	for _, name := range names {
+		for _, landmark := range orderedLandmarks(sessions[name]) {
+			if !known[landmark.Label] {
+				known[landmark.Label] = true
+				labels = append(labels, landmark.Label)
+			}
+		}
+	}
+	sessionPositions := make(map[string]map[string][]float64)
+	for _, name := range names {
+		sessionPositions[name] = exportedPositions(sessions[name])
+	}
+	for _, label := range labels {
+		positions := make([]mat.Vector, 0, len(names))
+		for _, name := range names {
+			if position, ok := sessionPositions[name][label]; ok {
+				positions = append(positions, mat.NewVecDense(len(position), position))
+			}
+		}
+		mean, rms, max := morph.Dispersion(positions)
+		report.Landmarks = append(report.Landmarks, LandmarkError{
+			Label:    label,
+			Sessions: len(positions),
+			Mean:     mean.RawVector().Data,
+			RMS:      rms,
+			Max:      max,
+		})
+	}
+
+	distances, lengths := sessionDistances(names, sessions)
+	for _, label := range distances {
+		values := make([]float64, 0, len(lengths[label]))
+		for _, name := range names {
+			if length, ok := lengths[label][name]; ok {
+				values = append(values, length)
+			}
+		}
+		if len(values) == 0 {
+			continue
+		}
+		mean, sd := stat.MeanStdDev(values, nil)
+		if len(values) == 1 {
+			sd = 0
+		}
+		distance := DistanceError{Label: label, Sessions: len(values), Mean: mean, SD: sd, Min: values[0], Max: values[0]}
+		if mean != 0 {
+			distance.CV = 100 * sd / mean
+		}
+		for _, value := range values {
+			distance.Min = math.Min(distance.Min, value)
+			distance.Max = math.Max(distance.Max, value)
+		}
+		report.Distances = append(report.Distances, distance)
+	}
+	return report, nil
+}
+
+// distanceRepeatability computes the ICC(2,1) of every distance, with the specimens as rows and the sessions as columns.
+// A specimen counts for a distance if it is measured in all its sessions, the ICC is missing below 2 specimens
+func distanceRepeatability(specimens []string, names []string, sessions []map[string]ExportJSON) RepeatabilityReport {
+	report := RepeatabilityReport{Specimens: specimens, Sessions: names, Distances: []DistanceRepeatability{}}
+
+	labels := make([]string, 0)
+	lengths := make([]map[string]map[string]float64, len(specimens))
+	known := make(map[string]bool)
+	for index := range specimens {
+		var specimenLabels []string
+		specimenLabels, lengths[index] = sessionDistances(names, sessions[index])
+		for _, label := range specimenLabels {
+			if !known[label] {
+				known[label] = true
+				labels = append(labels, label)
+			}
+		}
+	}
+
+	for _, label := range labels {
+		rows := make([][]float64, 0, len(specimens))
+		for index := range specimens {
+			row := make([]float64, 0, len(names))
+			for _, name := range names {
+				if length, ok := lengths[index][label][name]; ok {
+					row = append(row, length)
+				}
+			}
+			if len(row) == len(names) {
+				rows = append(rows, row)
+			}
+		}
+
+		distance := DistanceRepeatability{Label: label, Specimens: len(rows)}
+		if len(rows) >= 2 {
+			data := mat.NewDense(len(rows), len(names), nil)
+			for index, row := range rows {
+				data.SetRow(index, row)
+			}
+			if icc, err := morph.ICC(data); err == nil {
+				distance.ICC = &icc
+			} else {
+				log.Printf("ICC of %s : %v\n", label, err)
+			}
+		}
+		report.Distances = append(report.Distances, distance)
+	}
+	return report
+}
+
+func formatValue(value float64) string {
+	return strconv.FormatFloat(value, 'g', 6, 64)
+}
+
+// writeMeasurementError writes the report as a CSV file, with a table for the landmarks and one for the distances
+func writeMeasurementError(w io.Writer, report MeasurementErrorReport) error {
+	rows := [][]string{
+		append([]string{"Sessions"}, report.Sessions...),
+		{},
+		{"Landmark", "Sessions", "X", "Y", "Z", "RMS", "Max"},
+	}
+	for _, landmark := range report.Landmarks {
+		rows = append(rows, []string{
+			landmark.Label, strconv.Itoa(landmark.Sessions),
+			formatCoordinate(landmark.Mean[0]), formatCoordinate(landmark.Mean[1]), formatCoordinate(landmark.Mean[2]),
+			formatValue(landmark.RMS), formatValue(landmark.Max),
+		})
+	}
+	rows = append(rows, []string{}, []string{"Distance", "Sessions", "Mean", "SD", "CV (%)", "Min", "Max"})
+	for _, distance := range report.Distances {
+		rows = append(rows, []string{
+			distance.Label, strconv.Itoa(distance.Sessions),
+			formatValue(distance.Mean), formatValue(distance.SD), formatValue(distance.CV),
+			formatValue(distance.Min), formatValue(distance.Max),
+		})
+	}
+	return writeRows(w, rows)
+}
+
+// writeRepeatability writes the ICC of every distance as a CSV file
+func writeRepeatability(w io.Writer, report RepeatabilityReport) error {
+	rows := [][]string{
+		append([]string{"Specimens"}, report.Specimens...),
+		append([]string{"Sessions"}, report.Sessions...),
+		{},
+		{"Distance", "Specimens", "ICC(2,1)"},
+	}
+	for _, distance := range report.Distances {
+		icc := MISSING_TEXT
+		if distance.ICC != nil {
+			icc = formatValue(*distance.ICC)
+		}
+		rows = append(rows, []string{distance.Label, strconv.Itoa(distance.Specimens), icc})
+	}
+	return writeRows(w, rows)
+}
+
+func writeRows(w io.Writer, rows [][]string) error {
+	writer := csv.NewWriter(w)
+	if err := writer.WriteAll(rows); err != nil {
+		return err
+	}
+	return writer.Error()
+}
+
+// saveReport asks where to save a CSV report and writes it, returns "" if it was cancelled
+func (a *App) saveReport(dir string, name string, write func(io.Writer) error) (string, error) {
+	path, err := runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
+		DefaultDirectory: dir,
+		DefaultFilename:  fmt.Sprintf("%s_%s.csv", name, time.Now().Format("20060102_150405")),
+		Filters: []runtime.FileFilter{{
+			DisplayName: "CSV (.csv)",
+			Pattern:     "*.csv",
+		}},
+	})
+	if err != nil || path == "" {
+		return "", err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if err := write(f); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// Measurement error between stored landmark sessions of the project (repeats or observers),
+// every session is used if none is given. The report is saved as CSV if asked
+func (a *App) MeasurementError(projectFile string, names []string, save bool) (MeasurementErrorReport, error) {
+	log.Println("Measurement error")
+	_, release, err := a.acquire(projectFile)
+	if err != nil {
+		return MeasurementErrorReport{}, newError(ERROR_PROJECT, err)
+	}
+	sessions, err := readSessions(projectFile)
+	release()
+	if err != nil {
+		return MeasurementErrorReport{}, newError(ERROR_FILE, err)
+	}
+	if len(names) == 0 {
+		for name := range sessions {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, ok := sessions[name]; !ok {
+			return MeasurementErrorReport{}, errorf(ERROR_INVALID_ARGUMENT, "unknown session %s", name)
+		}
+	}
+
+	report, err := measurementError(names, sessions)
+	if err != nil {
+		return MeasurementErrorReport{}, newError(ERROR_COMPUTATION, err)
+	}
+	if !save {
+		return report, nil
+	}
+	output, err := a.saveReport(filepath.Dir(projectFile), "measurement_error", func(w io.Writer) error {
+		return writeMeasurementError(w, report)
+	})
+	if err != nil {
+		return report, newError(ERROR_FILE, err)
+	}
+	report.Output = output
+	return report, nil
+}
+
+// Repeatability (ICC) of the distances measured in the same sessions (repeats or observers) of several projects,
+// one specimen each. The sessions found in every project are used if none is given. The report is saved as CSV if asked
+func (a *App) Repeatability(projectFiles []string, names []string, save bool) (RepeatabilityReport, error) {
+	log.Println("Repeatability")
+	if len(projectFiles) < 2 {
+		return RepeatabilityReport{}, errorf(ERROR_INVALID_ARGUMENT, "at least 2 specimens are needed, got %d", len(projectFiles))
+	}
+
+	specimens := make([]string, len(projectFiles))
+	sessions := make([]map[string]ExportJSON, len(projectFiles))
+	common := make(map[string]int)
+	for index, projectFile := range projectFiles {
+		// one project locked at a time, the others can't wait for each other
+		p, release, err := a.acquire(projectFile)
+		if err != nil {
+			return RepeatabilityReport{}, newError(ERROR_PROJECT, err)
+		}
+		specimens[index] = specimenID(&p.Metadata, projectFile)
+		sessions[index], err = readSessions(projectFile)
+		release()
+		if err != nil {
+			return RepeatabilityReport{}, newError(ERROR_FILE, err)
+		}
+		for name := range sessions[index] {
+			common[name]++
+		}
+	}
+	if len(names) == 0 {
+		for name, count := range common {
+			if count == len(projectFiles) {
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if common[name] != len(projectFiles) {
+			return RepeatabilityReport{}, errorf(ERROR_INVALID_ARGUMENT, "session %s isn't in every project", name)
+		}
+	}
+	if len(names) < 2 {
+		return RepeatabilityReport{}, errorf(ERROR_INVALID_ARGUMENT, "at least 2 sessions are needed, got %d", len(names))
+	}
+
+	report := distanceRepeatability(specimens, names, sessions)
+	if !save {
+		return report, nil
+	}
+	output, err := a.saveReport(filepath.Dir(projectFiles[0]), "repeatability", func(w io.Writer) error {
+		return writeRepeatability(w, report)
+	})
+	if err != nil {
+		return report, newError(ERROR_FILE, err)
+	}
+	report.Output = output
+	return report, nil
+}
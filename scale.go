@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+	"gonum.org/v1/gonum/mat"
+	imp "sphaeroptica.be/imports/imports"
+	sph "sphaeroptica.be/photogrammetry/photogrammetry"
+)
+
+// landmarkPositions returns the triangulated landmarks by label
+func landmarkPositions(landmarks ExportJSON) map[string][]float64 {
+	positions := make(map[string][]float64)
+	for _, landmark := range landmarks.Landmarks {
+		if len(landmark.Position) >= 3 {
+			positions[landmark.Label] = landmark.Position
+		}
+	}
+	return positions
+}
+
+// solveScale computes the scale factor of the bars from the landmarks triangulated in the current frame
+func solveScale(bars []ScaleBar, landmarks ExportJSON) (ScaleResult, error) {
+	positions := landmarkPositions(landmarks)
+
+	measured := make([]float64, len(bars))
+	known := make([]float64, len(bars))
+	for index, bar := range bars {
+		left, okLeft := positions[bar.Left]
+		right, okRight := positions[bar.Right]
+		if !okLeft || !okRight {
+			return ScaleResult{}, fmt.Errorf("scale bar %s : landmarks %s and %s must be triangulated", bar.Label, bar.Left, bar.Right)
+		}
+		measured[index] = sph.Distance(mat.NewVecDense(len(left), left), mat.NewVecDense(len(right), right))
+		known[index] = bar.Length
+	}
+
+	scale, errors, err := sph.ScaleFromBars(measured, known)
+	if err != nil {
+		return ScaleResult{}, err
+	}
+	residuals := make([]Residual, len(bars))
+	for index, bar := range bars {
+		residuals[index] = Residual{Label: bar.Label, Error: errors[index]}
+	}
+	return ScaleResult{Scale: scale, Residuals: residuals, RMS: sph.RMS(errors)}, nil
+}
+
+// metric reports if the project is in millimetres, scaled by its bars and not reset since
+func (p *project) metric() bool {
+	return p != nil && len(p.ScaleBars) > 0 && p.Transform != nil
+}
+
+// Get the scale bars of the project
+func (a *App) ScaleBars(projectFile string) ([]ScaleBar, error) {
+	p, release, err := a.acquire(projectFile)
+	if err != nil {
+		return []ScaleBar{}, newError(ERROR_PROJECT, err)
+	}
+	defer release()
+
+	return p.ScaleBars, nil
+}
+
+// Import scale bars between coded targets from a CSV file (left,right,length)
+func (a *App) ImportScaleBars() ([]ScaleBar, error) {
+	file := a.openFileDialog("Select Scale Bars File", []runtime.FileFilter{
+		{
+			DisplayName: "Scale bars (*.csv;*.txt)",
+			Pattern:     "*.csv;*.txt",
+		},
+	})
+	if file == "" {
+		return []ScaleBar{}, nil
+	}
+	imported, err := imp.ReadScaleBars(file)
+	if err != nil {
+		return []ScaleBar{}, newError(ERROR_FILE, err)
+	}
+
+	bars := make([]ScaleBar, len(imported))
+	for index, bar := range imported {
+		bars[index] = ScaleBar{Label: bar.Label, Left: bar.Left, Right: bar.Right, Length: bar.Length}
+	}
+	return bars, nil
+}
+
+// Compute the scale factor (and residuals in millimetres) of the bars without applying it
+func (a *App) SolveScale(bars []ScaleBar, landmarks ExportJSON) (ScaleResult, error) {
+	result, err := solveScale(bars, landmarks)
+	if err != nil {
+		return ScaleResult{}, newError(ERROR_COMPUTATION, err)
+	}
+	return result, nil
+}
+
+// Scale the project to millimetres, the landmarks have to be triangulated again afterwards
+func (a *App) ApplyScale(projectFile string, bars []ScaleBar, landmarks ExportJSON) (ScaleResult, error) {
+	p, release, err := a.acquire(projectFile)
+	if err != nil {
+		return ScaleResult{}, newError(ERROR_PROJECT, err)
+	}
+	defer release()
+
+	result, err := solveScale(bars, landmarks)
+	if err != nil {
+		return ScaleResult{}, newError(ERROR_COMPUTATION, err)
+	}
+
+	p.applyTransform(sph.ScaleSimilarity(result.Scale))
+	p.ScaleBars = bars
+	if err := saveProjectFile(projectFile, p); err != nil {
+		return result, newError(ERROR_PROJECT, err)
+	}
+	return result, nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"math"
+	"sort"
+
+	"gonum.org/v1/gonum/mat"
+	sph "sphaeroptica.be/photogrammetry/photogrammetry"
+)
+
+// Selection of the views (images) for the navigation and the review of the landmarks
+
+// cameraIndex holds the directions of the images, computed once per frame of the project
+type cameraIndex struct {
+	images []string
+	// unit vectors from the center of the sphere to the cameras, in the specimen frame if oriented
+	directions map[string]*mat.VecDense
+	// camera centers and optical axes in the project frame
+	centers map[string]mat.Vector
+	axes    map[string]*mat.VecDense
+}
+
+// cameras returns the index of the images, built again after a change of frame
+func (p *project) cameras() *cameraIndex {
+	if p.index != nil {
+		return p.index
+	}
+	index := cameraIndex{
+		images:     make([]string, 0, len(p.Extrinsics)),
+		directions: make(map[string]*mat.VecDense),
+		centers:    make(map[string]mat.Vector),
+		axes:       make(map[string]*mat.VecDense),
+	}
+	for image, vector := range p.cameraVectors() {
+		var direction mat.VecDense
+		direction.ScaleVec(1/vector.Norm(2), vector)
+		index.images = append(index.images, image)
+		index.directions[image] = &direction
+		index.centers[image] = p.cameraCenter(image)
+		// the optical axis is the third row of the rotation
+		index.axes[image] = mat.NewVecDense(3, mat.Row(nil, 2, p.extrinsics(image).Slice(0, 3, 0, 3)))
+	}
+	sort.Strings(index.images)
+	p.index = &index
+	return p.index
+}
+
+// nearestView returns the image whose camera direction is the closest to the geographic coordinates (in degrees)
+func (p *project) nearestView(coordinates sph.Coordinates) string {
+	target := sph.LongLatToVector(sph.Degrees2Rad(coordinates.Longitude), sph.Degrees2Rad(coordinates.Latitude))
+	index := p.cameras()
+	nearest := ""
+	best := math.Inf(-1)
+	for _, image := range index.images {
+		if cosine := mat.Dot(target, index.directions[image]); cosine > best {
+			nearest, best = image, cosine
+		}
+	}
+	return nearest
+}
+
+// visibleViews returns the images where the point is visible, the best ones first:
+// the score favours the cameras looking straight at the point (small angle to the optical axis) and close to it
+func (p *project) visibleViews(position []float64) []ViewScore {
+	index := p.cameras()
+	point := mat.NewVecDense(3, position[:3])
+	coordinates := p.imageCoordinates()
+
+	views := make([]ViewScore, 0)
+	nearest := math.Inf(1)
+	for _, image := range index.images {
+		pose, visible := p.reprojectVisible(image, position)
+		if !visible {
+			continue
+		}
+		var ray mat.VecDense
+		ray.SubVec(point, index.centers[image])
+		distance := ray.Norm(2)
+		if distance == 0 {
+			continue
+		}
+		cosine := math.Max(-1, math.Min(1, mat.Dot(&ray, index.axes[image])/distance))
+		views = append(views, ViewScore{
+			Image:       image,
+			Coordinates: coordinates[image],
+			Pose:        pose,
+			Angle:       sph.Rad2Degrees(math.Acos(cosine)),
+			Distance:    distance,
+			Score:       cosine,
+		})
+		nearest = math.Min(nearest, distance)
+	}
+	for index := range views {
+		views[index].Score *= nearest / views[index].Distance
+	}
+	sort.SliceStable(views, func(i, j int) bool {
+		if views[i].Score == views[j].Score {
+			return views[i].Image < views[j].Image
+		}
+		return views[i].Score > views[j].Score
+	})
+	return views
+}
+
+// Get the image the nearest to the geographic coordinates (in degrees) of the viewer
+func (a *App) NearestView(projectFile string, coordinates sph.Coordinates) (string, error) {
+	p, release, err := a.acquire(projectFile)
+	if err != nil {
+		return "", newError(ERROR_PROJECT, err)
+	}
+	defer release()
+	return p.nearestView(coordinates), nil
+}
+
+// Get the count best views of a 3D point, by viewing angle and distance, among the images where it is visible
+func (a *App) BestViews(projectFile string, position []float64, count int) ([]ViewScore, error) {
+	views, err := a.LandmarkViews(projectFile, position)
+	if count >= 0 && len(views) > count {
+		views = views[:count]
+	}
+	return views, err
+}
+
+// Get every image where the 3D point (a triangulated landmark) is visible, the best views first
+func (a *App) LandmarkViews(projectFile string, position []float64) ([]ViewScore, error) {
+	p, release, err := a.acquire(projectFile)
+	if err != nil {
+		return []ViewScore{}, newError(ERROR_PROJECT, err)
+	}
+	defer release()
+	if len(position) < 3 {
+		return []ViewScore{}, errorf(ERROR_INVALID_ARGUMENT, "the landmark isn't triangulated")
+	}
+	return p.visibleViews(position), nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Landmark sessions are stored next to the project as sessions/<project>/<name>.json
+// (sessions/<name>.json in its archive), in the same format as CreateLandmarksJSON
+const SESSIONS_DIR = "sessions"
+
+// sessionsDir returns the directory of the sessions among the project files,
+// a directory can hold several projects so each one has its own
+func sessionsDir(projectFile string) string {
+	if isArchive(projectFile) {
+		return SESSIONS_DIR
+	}
+	return path.Join(SESSIONS_DIR, strings.TrimSuffix(filepath.Base(projectFile), filepath.Ext(projectFile)))
+}
+
+func sessionEntry(projectFile string, name string) (string, error) {
+	name = strings.TrimSuffix(name, ".json")
+	if name == "" || name != filepath.Base(name) || strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("invalid session name %q", name)
+	}
+	return path.Join(sessionsDir(projectFile), name+".json"), nil
+}
+
+// sessionEntries returns the session files of the project
+func sessionEntries(files fs.FS, projectFile string) ([]string, error) {
+	dir := sessionsDir(projectFile)
+	found, err := fs.ReadDir(files, dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]string, 0, len(found))
+	for _, entry := range found {
+		if !entry.IsDir() && path.Ext(entry.Name()) == ".json" {
+			entries = append(entries, path.Join(dir, entry.Name()))
+		}
+	}
+	return entries, nil
+}
+
+func readSessions(projectFile string) (map[string]ExportJSON, error) {
+	sessions := make(map[string]ExportJSON)
+	err := useProjectFS(projectFile, func(files fs.FS) error {
+		entries, err := sessionEntries(files, projectFile)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			data, err := fs.ReadFile(files, entry)
+			if err != nil {
+				return err
+			}
+			var session ExportJSON
+			if err := json.Unmarshal(data, &session); err != nil {
+				log.Printf("Invalid session %s : %v\n", entry, err)
+				continue
+			}
+			sessions[strings.TrimSuffix(path.Base(entry), ".json")] = session
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// Get the landmark sessions of the project
+func (a *App) LandmarkSessions(projectFile string) (map[string]ExportJSON, error) {
+	_, release, err := a.acquire(projectFile)
+	if err != nil {
+		return map[string]ExportJSON{}, newError(ERROR_PROJECT, err)
+	}
+	defer release()
+
+	sessions, err := readSessions(projectFile)
+	if err != nil {
+		return map[string]ExportJSON{}, newError(ERROR_FILE, err)
+	}
+	return sessions, nil
+}
+
+// Save a landmark session in the project
+func (a *App) SaveLandmarkSession(projectFile string, name string, landmarks ExportJSON) error {
+	entry, err := sessionEntry(projectFile, name)
+	if err != nil {
+		return newError(ERROR_INVALID_ARGUMENT, err)
+	}
+	landmarks.Measures = computeMeasures(landmarks)
+	landmarks.Curves = computeCurves(landmarks)
+	data, err := json.MarshalIndent(landmarks, "", "  ")
+	if err != nil {
+		return newError(ERROR_FILE, err)
+	}
+
+	_, release, err := a.acquire(projectFile)
+	if err != nil {
+		return newError(ERROR_PROJECT, err)
+	}
+	defer release()
+
+	if isArchive(projectFile) {
+		err = updateArchive(projectFile, map[string][]byte{entry: data})
+	} else {
+		sessionFile := filepath.Join(filepath.Dir(projectFile), filepath.FromSlash(entry))
+		if err = os.MkdirAll(filepath.Dir(sessionFile), os.ModePerm); err == nil {
+			err = os.WriteFile(sessionFile, data, 0644)
+		}
+	}
+	if err != nil {
+		return newError(ERROR_FILE, err)
+	}
+	return nil
+}
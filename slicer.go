@@ -0,0 +1,379 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+	"gonum.org/v1/gonum/mat"
+)
+
+// 3D Slicer markups (https://slicer.readthedocs.io/en/latest/developer_guide/modules/markups.html)
+// The project frame is taken as the LPS frame of the registered scan, RAS coordinates have their X and Y negated
+
+const SLICER_SCHEMA = "https://raw.githubusercontent.com/slicer/slicer/master/Modules/Loadable/Markups/Resources/Schema/markups-schema-v1.0.3.json#"
+const SLICER_FCSV_HEADER = `# Markups fiducial file version = 4.11
+# CoordinateSystem = %s
+# columns = id,x,y,z,ow,ox,oy,oz,vis,sel,lock,label,desc,associatedNodeID
+`
+const DEFAULT_LANDMARK_COLOR = "#ff0000"
+
+// The project transform is written next to the markups as an ITK transform file Slicer can load
+const SLICER_TRANSFORM_EXT = ".tfm"
+const SLICER_TRANSFORM = `#Insight Transform File V1.0
+#Transform 0
+Transform: AffineTransform_double_3_3
+Parameters: %s
+FixedParameters: 0 0 0
+`
+
+type slicerMarkups struct {
+	Schema  string         `json:"@schema"`
+	Markups []slicerMarkup `json:"markups"`
+}
+
+type slicerMarkup struct {
+	Type             string               `json:"type"`
+	CoordinateSystem string               `json:"coordinateSystem"`
+	CoordinateUnits  string               `json:"coordinateUnits,omitempty"`
+	ControlPoints    []slicerControlPoint `json:"controlPoints"`
+	Display          *slicerDisplay       `json:"display,omitempty"`
+}
+
+type slicerControlPoint struct {
+	ID             string    `json:"id"`
+	Label          string    `json:"label"`
+	Description    string    `json:"description"`
+	Position       []float64 `json:"position"`
+	PositionStatus string    `json:"positionStatus"`
+}
+
+type slicerDisplay struct {
+	Color         []float64 `json:"color"`
+	SelectedColor []float64 `json:"selectedColor"`
+}
+
+// toSlicer converts a position of the project frame to the coordinate system (RAS or LPS), and back
+func toSlicer(position []float64, coordinateSystem string) []float64 {
+	if strings.EqualFold(coordinateSystem, "RAS") {
+		return []float64{-position[0], -position[1], position[2]}
+	}
+	return []float64{position[0], position[1], position[2]}
+}
+
+func fromSlicer(position []float64, coordinateSystem string) []float64 {
+	converted := toSlicer(position, coordinateSystem)
+	return []float64{converted[0], converted[1], converted[2], 1}
+}
+
+func checkCoordinateSystem(coordinateSystem string) (string, error) {
+	coordinateSystem = strings.ToUpper(coordinateSystem)
+	if coordinateSystem != "RAS" && coordinateSystem != "LPS" {
+		return "", fmt.Errorf("unknown coordinate system %s", coordinateSystem)
+	}
+	return coordinateSystem, nil
+}
+
+// parseColor reads a "#rrggbb" color as rgb values in [0, 1]
+func parseColor(color string) ([]float64, error) {
+	color = strings.TrimPrefix(strings.TrimSpace(color), "#")
+	if len(color) != 6 {
+		return nil, fmt.Errorf("invalid color %s", color)
+	}
+	rgb := make([]float64, 3)
+	for index := range rgb {
+		value, err := strconv.ParseUint(color[2*index:2*index+2], 16, 8)
+		if err != nil {
+			return nil, err
+		}
+		rgb[index] = float64(value) / 255
+	}
+	return rgb, nil
+}
+
+func formatColor(rgb []float64) string {
+	return fmt.Sprintf("#%02x%02x%02x", int(rgb[0]*255+0.5), int(rgb[1]*255+0.5), int(rgb[2]*255+0.5))
+}
+
+// Slicer has a single color per markup node, the color of every landmark is kept in its description
+func colorDescription(color string) string {
+	return "color=" + color
+}
+
+func descriptionColor(description string) string {
+	for _, field := range strings.Fields(description) {
+		if strings.HasPrefix(field, "color=") {
+			return strings.TrimPrefix(field, "color=")
+		}
+	}
+	return ""
+}
+
+// writeSlicerMarkups writes the landmarks as a markups node, the coordinates are in millimetres
+// only once the project is scaled, the unit is left out otherwise
+func writeSlicerMarkups(w io.Writer, landmarks ExportJSON, coordinateSystem string, metric bool) error {
+	markup := slicerMarkup{
+		Type:             "Fiducial",
+		CoordinateSystem: coordinateSystem,
+		ControlPoints:    []slicerControlPoint{},
+	}
+	if metric {
+		markup.CoordinateUnits = "mm"
+	}
+
+	colors := make(map[string]int)
+	for index, landmark := range orderedLandmarks(landmarks) {
+		point := slicerControlPoint{
+			ID:             strconv.Itoa(index + 1),
+			Label:          landmark.Label,
+			Description:    colorDescription(landmark.Color),
+			Position:       []float64{0, 0, 0},
+			PositionStatus: "missing",
+		}
+		if len(landmark.Position) >= 3 {
+			point.Position = toSlicer(landmark.Position, coordinateSystem)
+			point.PositionStatus = "defined"
+		}
+		markup.ControlPoints = append(markup.ControlPoints, point)
+		colors[landmark.Color]++
+	}
+
+	// the node takes the most used color
+	mainColor := DEFAULT_LANDMARK_COLOR
+	for color, count := range colors {
+		if count > colors[mainColor] || (count == colors[mainColor] && color < mainColor) {
+			mainColor = color
+		}
+	}
+	if rgb, err := parseColor(mainColor); err == nil {
+		markup.Display = &slicerDisplay{Color: rgb, SelectedColor: rgb}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "    ")
+	return encoder.Encode(slicerMarkups{Schema: SLICER_SCHEMA, Markups: []slicerMarkup{markup}})
+}
+
+// writeSlicerFCSV writes the legacy format, which has no notion of missing landmarks so they are skipped
+func writeSlicerFCSV(w io.Writer, landmarks ExportJSON, coordinateSystem string) error {
+	if _, err := fmt.Fprintf(w, SLICER_FCSV_HEADER, coordinateSystem); err != nil {
+		return err
+	}
+	writer := csv.NewWriter(w)
+	for index, landmark := range orderedLandmarks(landmarks) {
+		if len(landmark.Position) < 3 {
+			continue
+		}
+		position := toSlicer(landmark.Position, coordinateSystem)
+		err := writer.Write([]string{
+			fmt.Sprintf("vtkMRMLMarkupsFiducialNode_%d", index),
+			formatCoordinate(position[0]), formatCoordinate(position[1]), formatCoordinate(position[2]),
+			"0", "0", "0", "1",
+			"1", "1", "0",
+			landmark.Label, colorDescription(landmark.Color), "",
+		})
+		if err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// slicerTransformPath returns the path of the transform written next to the markups file
+func slicerTransformPath(markupsPath string) string {
+	base := strings.TrimSuffix(markupsPath, filepath.Ext(markupsPath))
+	if strings.EqualFold(filepath.Ext(base), ".mrk") {
+		base = strings.TrimSuffix(base, filepath.Ext(base))
+	}
+	return base + SLICER_TRANSFORM_EXT
+}
+
+// writeSlicerTransform writes the transform from the calibration frame to the project frame.
+// ITK files hold the resampling transform (from the parent frame), so the inverse is written:
+// Slicer moves the nodes in the calibration frame put under it to the project frame
+func writeSlicerTransform(markupsPath string, export LandmarksExport) error {
+	if export.Project == nil {
+		return fmt.Errorf("no project is open")
+	}
+	inverse := export.Project.transform().Inverse()
+
+	var matrix mat.Dense
+	matrix.Scale(inverse.Scale, inverse.RotationMatrix())
+	parameters := make([]string, 0, 12)
+	for _, value := range append(matrix.RawMatrix().Data, inverse.Translation...) {
+		parameters = append(parameters, formatCoordinate(value))
+	}
+
+	f, err := os.Create(slicerTransformPath(markupsPath))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = fmt.Fprintf(f, SLICER_TRANSFORM, strings.Join(parameters, " "))
+	return err
+}
+
+// readSlicerMarkups reads the fiducials of the markups, the other markups (curves, planes, ROI...) are skipped
+func readSlicerMarkups(data []byte) ([]LandmarkJSON, error) {
+	var markups slicerMarkups
+	if err := json.Unmarshal(data, &markups); err != nil {
+		return nil, err
+	}
+
+	landmarks := []LandmarkJSON{}
+	for _, markup := range markups.Markups {
+		if !strings.EqualFold(markup.Type, "Fiducial") {
+			log.Printf("Slicer markup %s skipped, only fiducials are landmarks\n", markup.Type)
+			continue
+		}
+		nodeColor := DEFAULT_LANDMARK_COLOR
+		if markup.Display != nil && len(markup.Display.Color) == 3 {
+			nodeColor = formatColor(markup.Display.Color)
+		}
+		coordinateSystem := markup.CoordinateSystem
+		if coordinateSystem == "" {
+			coordinateSystem = "LPS"
+		}
+		for _, point := range markup.ControlPoints {
+			landmark := LandmarkJSON{Label: point.Label, Color: nodeColor, Poses: map[string]PoseJSON{}}
+			if color := descriptionColor(point.Description); color != "" {
+				landmark.Color = color
+			}
+			if point.PositionStatus != "missing" && len(point.Position) == 3 {
+				landmark.Position = fromSlicer(point.Position, coordinateSystem)
+			}
+			landmarks = append(landmarks, landmark)
+		}
+	}
+	return landmarks, nil
+}
+
+func readSlicerFCSV(data []byte) ([]LandmarkJSON, error) {
+	coordinateSystem := "LPS"
+	lines := strings.Split(string(data), "\n")
+	for _, line := range lines {
+		if strings.HasPrefix(line, "# CoordinateSystem") {
+			value := strings.TrimSpace(line[strings.Index(line, "=")+1:])
+			// versions before 4.11 write 0 for RAS and 1 for LPS
+			switch value {
+			case "0", "RAS":
+				coordinateSystem = "RAS"
+			case "1", "LPS":
+				coordinateSystem = "LPS"
+			}
+		}
+	}
+
+	reader := csv.NewReader(strings.NewReader(string(data)))
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+
+	landmarks := []LandmarkJSON{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(record) < 13 {
+			return nil, fmt.Errorf("invalid fcsv line %v", record)
+		}
+		position := make([]float64, 3)
+		for index := range position {
+			position[index], err = strconv.ParseFloat(record[index+1], 64)
+			if err != nil {
+				return nil, err
+			}
+		}
+		landmark := LandmarkJSON{
+			Label:    record[11],
+			Color:    DEFAULT_LANDMARK_COLOR,
+			Position: fromSlicer(position, coordinateSystem),
+			Poses:    map[string]PoseJSON{},
+		}
+		if color := descriptionColor(record[12]); color != "" {
+			landmark.Color = color
+		}
+		landmarks = append(landmarks, landmark)
+	}
+	return landmarks, nil
+}
+
+// Export the landmarks as 3D Slicer markups (.mrk.json), or as the legacy .fcsv,
+// with the project transform next to them (.tfm)
+func (a *App) CreateSlicerMarkups(projectFile string, landmarks ExportJSON, coordinateSystem string, legacy bool) error {
+	log.Println("Create Slicer Markups")
+	coordinateSystem, err := checkCoordinateSystem(coordinateSystem)
+	if err != nil {
+		return newError(ERROR_INVALID_ARGUMENT, err)
+	}
+
+	if legacy {
+		return a.ExportLandmarks(projectFile, "SlicerFCSV"+coordinateSystem, landmarks)
+	}
+	return a.ExportLandmarks(projectFile, "SlicerMarkups"+coordinateSystem, landmarks)
+}
+
+// Import landmarks placed in 3D Slicer on a scan registered to the project frame,
+// they are reprojected on every image where they are visible
+func (a *App) ImportSlicerMarkups(projectFile string) ([]LandmarkJSON, error) {
+	file := a.openFileDialog("Select Slicer Markups", []runtime.FileFilter{
+		{
+			DisplayName: "Slicer Markups (*.mrk.json;*.json;*.fcsv)",
+			Pattern:     "*.mrk.json;*.json;*.fcsv",
+		},
+	})
+	if file == "" {
+		return []LandmarkJSON{}, nil
+	}
+
+	p, release, err := a.acquire(projectFile)
+	if err != nil {
+		return []LandmarkJSON{}, newError(ERROR_PROJECT, err)
+	}
+	defer release()
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return []LandmarkJSON{}, newError(ERROR_FILE, err)
+	}
+
+	var landmarks []LandmarkJSON
+	if strings.EqualFold(filepath.Ext(file), ".fcsv") {
+		landmarks, err = readSlicerFCSV(data)
+	} else {
+		landmarks, err = readSlicerMarkups(data)
+	}
+	if err != nil {
+		return []LandmarkJSON{}, newError(ERROR_FILE, err)
+	}
+
+	images := make([]string, 0, len(p.Extrinsics))
+	for image := range p.Extrinsics {
+		images = append(images, image)
+	}
+	sort.Strings(images)
+
+	for _, landmark := range landmarks {
+		if len(landmark.Position) < 3 {
+			continue
+		}
+		for _, image := range images {
+			if pos, visible := p.reprojectVisible(image, landmark.Position); visible {
+				landmark.Poses[image] = PoseJSON{X: pos.X, Y: pos.Y}
+			}
+		}
+	}
+	return landmarks, nil
+}
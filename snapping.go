@@ -0,0 +1,46 @@
+package main
+
+import (
+	sph "sphaeroptica.be/photogrammetry/photogrammetry"
+)
+
+// Snapping of the clicked pixels to the features of the full resolution images
+
+// half size (in pixels) of the window searched around the click
+const SNAP_RADIUS = 15
+
+const (
+	FEATURE_NONE   = ""
+	FEATURE_CORNER = "corner"
+	FEATURE_BLOB   = "blob"
+)
+
+// Refine the pixel clicked on the image to the corner or the blob under it, at sub pixel precision
+// A corner is kept whenever one is found (its roundness and the confidence of a blob aren't comparable),
+// the blob otherwise, the pixel is returned as is (confidence 0) if none is found.
+// The full image is decoded without the project lock
+func (a *App) SnapPose(projectFile string, imageName string, pose sph.Pos) (Snap, error) {
+	snap := Snap{Pose: pose, Confidence: 0, Feature: FEATURE_NONE}
+	p, release, err := a.acquire(projectFile)
+	if err != nil {
+		return snap, newError(ERROR_PROJECT, err)
+	}
+	err = p.checkImages(imageName)
+	release()
+	if err != nil {
+		return snap, newError(ERROR_UNKNOWN_IMAGE, err)
+	}
+
+	img, err := fullImage(projectFile, imageName)
+	if err != nil {
+		return snap, newError(ERROR_FILE, err)
+	}
+
+	if corner, roundness, err := sph.ForstnerCorner(img, pose, SNAP_RADIUS); err == nil {
+		return Snap{Pose: corner, Confidence: roundness, Feature: FEATURE_CORNER}, nil
+	}
+	if centroid, confidence, err := sph.BlobCentroid(img, pose, SNAP_RADIUS); err == nil {
+		return Snap{Pose: centroid, Confidence: confidence, Feature: FEATURE_BLOB}, nil
+	}
+	return snap, nil
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// Missing values of the text exports (TPS, CSV) are written as NA,
+// read as missing values by R (geomorph estimate.missing, read.csv)
+const MISSING_TEXT = "NA"
+
+// orderedLandmarks returns the fixed landmarks in the order they are exported:
+// the order of the protocol (with the landmarks not placed left missing), then the others by key.
+// The landmarks clicked along the curves between their ends only define the curves, they are left out
+func orderedLandmarks(landmarks ExportJSON) []LandmarkJSON {
+	ordered := make([]LandmarkJSON, 0, len(landmarks.Landmarks))
+	inProtocol := make(map[string]bool)
+	if landmarks.Protocol != nil {
+		byLabel := make(map[string]LandmarkJSON)
+		for _, landmark := range landmarks.Landmarks {
+			byLabel[landmark.Label] = landmark
+		}
+		for _, expected := range landmarks.Protocol.Landmarks {
+			landmark, ok := byLabel[expected.Name]
+			if !ok {
+				landmark = LandmarkJSON{Label: expected.Name, Color: expected.Color}
+			}
+			inProtocol[expected.Name] = true
+			ordered = append(ordered, landmark)
+		}
+	}
+
+	alongCurves := curvePoints(landmarks)
+	keys := make([]string, 0, len(landmarks.Landmarks))
+	for key, landmark := range landmarks.Landmarks {
+		if !inProtocol[landmark.Label] && !alongCurves[landmark.Label] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		ordered = append(ordered, landmarks.Landmarks[key])
+	}
+	return ordered
+}
+
+// tpsLandmarks returns the fixed landmarks followed by the semilandmarks of the curves, for TPS and geomorph
+func tpsLandmarks(landmarks ExportJSON) []LandmarkJSON {
+	return append(orderedLandmarks(landmarks), semilandmarks(landmarks)...)
+}
+
+// specimenID names the specimen in the exports, from its catalog number or the project file
+func specimenID(metadata *Metadata, projectFile string) string {
+	if metadata != nil && metadata.Specimen.CatalogNumber != "" {
+		if metadata.Specimen.InstitutionCode != "" {
+			return fmt.Sprintf("%s_%s", metadata.Specimen.InstitutionCode, metadata.Specimen.CatalogNumber)
+		}
+		return metadata.Specimen.CatalogNumber
+	}
+	return strings.TrimSuffix(filepath.Base(projectFile), filepath.Ext(projectFile))
+}
+
+func formatCoordinate(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// writeTPS writes the landmarks of one specimen as a LM3 block
+func writeTPS(w io.Writer, ordered []LandmarkJSON, scaleFactor float64, id string) error {
+	buffer := bufio.NewWriter(w)
+	fmt.Fprintf(buffer, "LM3=%d\n", len(ordered))
+	for _, landmark := range ordered {
+		if len(landmark.Position) < 3 {
+			fmt.Fprintf(buffer, "%s %s %s\n", MISSING_TEXT, MISSING_TEXT, MISSING_TEXT)
+			continue
+		}
+		fmt.Fprintf(buffer, "%s %s %s\n", formatCoordinate(landmark.Position[0]), formatCoordinate(landmark.Position[1]), formatCoordinate(landmark.Position[2]))
+	}
+	fmt.Fprintf(buffer, "ID=%s\n", strings.ReplaceAll(id, " ", "_"))
+	if scaleFactor != 0 {
+		fmt.Fprintf(buffer, "SCALE=%s\n", formatCoordinate(scaleFactor))
+	}
+	return buffer.Flush()
+}
+
+// tpsLandmarksCount returns the number of landmarks of the specimens of a TPS file (0 if it is empty)
+func tpsLandmarksCount(file string) (int, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(strings.ToUpper(line), "LM3=") {
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(line[len("LM3="):]))
+		if err != nil {
+			return 0, err
+		}
+		if count != 0 && n != count {
+			return 0, fmt.Errorf("%s mixes specimens with %d and %d landmarks", file, count, n)
+		}
+		count = n
+	}
+	return count, nil
+}
+
+// Export the landmarks to a TPS file, or append them as a new specimen of an existing TPS file
+func (a *App) CreateLandmarksTPS(projectFile string, landmarks ExportJSON, appendTo bool) error {
+	log.Println("Create TPS")
+	p, release, err := a.acquire(projectFile)
+	if err != nil {
+		return newError(ERROR_PROJECT, err)
+	}
+	landmarks.Protocol = p.protocol()
+	specimen := specimenID(p.metadata(), projectFile)
+	release()
+
+	var path string
+	if appendTo {
+		path, err = runtime.OpenFileDialog(a.ctx, runtime.OpenDialogOptions{
+			DefaultDirectory: filepath.Dir(projectFile),
+			Title:            "Select TPS File",
+			Filters: []runtime.FileFilter{{
+				DisplayName: "TPS (.tps)",
+				Pattern:     "*.tps",
+			}},
+		})
+	} else {
+		path, err = runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
+			DefaultDirectory: filepath.Dir(projectFile),
+			DefaultFilename:  fmt.Sprintf("landmarks_%s.tps", time.Now().Format("20060102_150405")),
+			Filters: []runtime.FileFilter{{
+				DisplayName: "TPS (.tps)",
+				Pattern:     "*.tps",
+			}},
+		})
+	}
+	if err != nil {
+		return newError(ERROR_FILE, err)
+	}
+	if path == "" {
+		return nil
+	}
+
+	flag := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if appendTo {
+		count, err := tpsLandmarksCount(path)
+		if err != nil {
+			return newError(ERROR_FILE, err)
+		}
+		if n := len(tpsLandmarks(landmarks)); count != 0 && count != n {
+			return errorf(ERROR_INVALID_ARGUMENT, "%s has %d landmarks per specimen, not %d", filepath.Base(path), count, n)
+		}
+		flag = os.O_APPEND | os.O_WRONLY
+	}
+
+	f, err := os.OpenFile(path, flag, 0644)
+	if err != nil {
+		return newError(ERROR_FILE, err)
+	}
+	defer f.Close()
+
+	err = writeTPS(f, tpsLandmarks(landmarks), landmarks.ScaleFactor, specimen)
+	if err != nil {
+		return newError(ERROR_FILE, err)
+	}
+	return nil
+}
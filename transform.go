@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"gonum.org/v1/gonum/mat"
+	sph "sphaeroptica.be/photogrammetry/photogrammetry"
+)
+
+// extrinsics returns the extrinsics of the image in the frame chosen for the project
+func (p *project) extrinsics(image string) *mat.Dense {
+	extrinsics := p.Extrinsics[image]
+	extrinsicsMat := mat.NewDense(extrinsics.Matrix.Shape.Row, extrinsics.Matrix.Shape.Col, extrinsics.Matrix.Data)
+	if p.Transform == nil {
+		return extrinsicsMat
+	}
+	return sph.TransformExtrinsics(extrinsicsMat, *p.Transform)
+}
+
+func (p *project) transform() sph.Similarity {
+	if p.Transform == nil {
+		return sph.IdentitySimilarity()
+	}
+	return *p.Transform
+}
+
+// applyTransform composes the project transform with a transform of its current frame,
+// the orientation is updated so the views and image coordinates stay the same.
+// Without orientation the views are in the project frame, they are rotated along with it
+func (p *project) applyTransform(similarity sph.Similarity) {
+	transform := p.transform().Then(similarity)
+	p.Transform = &transform
+	p.invalidate()
+
+	if orientation := p.orientation(); orientation != nil {
+		var rotation mat.Dense
+		rotation.Mul(orientation, similarity.RotationMatrix().T())
+		p.Orientation.Data = rotation.RawMatrix().Data
+		return
+	}
+	rotation := similarity.RotationMatrix()
+	for name, coordinates := range p.Commands {
+		var rotated mat.VecDense
+		rotated.MulVec(rotation, sph.LongLatToVector(sph.Degrees2Rad(coordinates.Longitude), sph.Degrees2Rad(coordinates.Latitude)))
+		p.Commands[name] = vectorCoordinates(&rotated)
+	}
+}
+
+// solveTransform computes the transform of the control points and applies it to the project
+func (p *project) solveTransform(points []ControlPoint) (TransformResult, error) {
+	src := make([]mat.Vector, 0, len(points))
+	dst := make([]mat.Vector, 0, len(points))
+	for _, point := range points {
+		if len(point.Position) < 3 || len(point.Reference) < 3 {
+			return TransformResult{}, fmt.Errorf("control point %s is incomplete", point.Label)
+		}
+		src = append(src, mat.NewVecDense(len(point.Position), point.Position))
+		dst = append(dst, mat.NewVecDense(len(point.Reference), point.Reference))
+	}
+
+	similarity, errors, err := sph.SimilarityFromPoints(src, dst)
+	if err != nil {
+		return TransformResult{}, err
+	}
+	p.applyTransform(similarity)
+
+	residuals := make([]Residual, len(points))
+	for index, point := range points {
+		residuals[index] = Residual{Label: point.Label, Error: errors[index]}
+	}
+	return TransformResult{Transform: p.transform(), Residuals: residuals, RMS: sph.RMS(errors)}, nil
+}
+
+// Get the transform from the calibration frame to the project frame
+func (a *App) Transform(projectFile string) (sph.Similarity, error) {
+	p, release, err := a.acquire(projectFile)
+	if err != nil {
+		return sph.IdentitySimilarity(), newError(ERROR_PROJECT, err)
+	}
+	defer release()
+
+	return p.transform(), nil
+}
+
+// Set the project frame from control points whose coordinates are known in the target frame
+func (a *App) SetTransformFromControlPoints(projectFile string, points []ControlPoint) (TransformResult, error) {
+	p, release, err := a.acquire(projectFile)
+	if err != nil {
+		return TransformResult{}, newError(ERROR_PROJECT, err)
+	}
+	defer release()
+
+	result, err := p.solveTransform(points)
+	if err != nil {
+		return TransformResult{}, newError(ERROR_COMPUTATION, err)
+	}
+	if err := saveProjectFile(projectFile, p); err != nil {
+		return result, newError(ERROR_PROJECT, err)
+	}
+	return result, nil
+}
+
+// Align the landmarks on a reference configuration (matched by label), e.g. a session of another specimen
+func (a *App) AlignLandmarks(projectFile string, landmarks ExportJSON, reference ExportJSON) (TransformResult, error) {
+	references := make(map[string][]float64)
+	for _, landmark := range reference.Landmarks {
+		references[landmark.Label] = landmark.Position
+	}
+
+	points := make([]ControlPoint, 0)
+	for _, landmark := range landmarks.Landmarks {
+		position, ok := references[landmark.Label]
+		if !ok || len(position) < 3 || len(landmark.Position) < 3 {
+			continue
+		}
+		points = append(points, ControlPoint{Label: landmark.Label, Position: landmark.Position, Reference: position})
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].Label < points[j].Label })
+
+	return a.SetTransformFromControlPoints(projectFile, points)
+}
+
+// Go back to the frame of the calibration
+func (a *App) ResetTransform(projectFile string) error {
+	p, release, err := a.acquire(projectFile)
+	if err != nil {
+		return newError(ERROR_PROJECT, err)
+	}
+	defer release()
+
+	if p.Transform != nil {
+		p.applyTransform(p.Transform.Inverse())
+		p.Transform = nil
+		p.invalidate()
+	}
+	if err := saveProjectFile(projectFile, p); err != nil {
+		return newError(ERROR_PROJECT, err)
+	}
+	return nil
+}
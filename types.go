@@ -7,11 +7,84 @@ import (
 
 type project struct {
 	Commands         map[string]sph.Coordinates
+	CommandsOrder    []string
 	Intrinsics       sph.Intrinsics
 	Extrinsics       map[string]sph.Extrinsics
+	CameraLabels     map[string]string
 	ThumbnailsWidth  int
 	ThumbnailsHeight int
 	Thumbnails       string
+	Metadata         Metadata
+	Orientation      *sph.MatrixInfo
+	Transform        *sph.Similarity
+	ScaleBars        []ScaleBar
+	Protocol         *Protocol
+
+	index *cameraIndex
+	cache *cameraMatrices
+}
+
+// Specimen metadata, named after the Darwin Core (dwc) and Audubon Core (ac) terms
+type Metadata struct {
+	Specimen    SpecimenMetadata    `json:"specimen"`
+	Acquisition AcquisitionMetadata `json:"acquisition"`
+	Licensing   LicensingMetadata   `json:"licensing"`
+}
+
+type SpecimenMetadata struct {
+	CatalogNumber   string `json:"catalogNumber"`
+	InstitutionCode string `json:"institutionCode"`
+	ScientificName  string `json:"scientificName"`
+}
+
+type AcquisitionMetadata struct {
+	Rig              string `json:"rig"`
+	Operator         string `json:"operator"`
+	Date             string `json:"date"`
+	StackingSoftware string `json:"stackingSoftware"`
+}
+
+type LicensingMetadata struct {
+	License string `json:"license"`
+	Owner   string `json:"owner"`
+	Credit  string `json:"credit"`
+}
+
+type View struct {
+	Name        string          `json:"name"`
+	Coordinates sph.Coordinates `json:"coordinates"`
+}
+
+// Point known in the current frame of the project and in the target frame
+type ControlPoint struct {
+	Label     string    `json:"label"`
+	Position  []float64 `json:"position"`
+	Reference []float64 `json:"reference"`
+}
+
+type Residual struct {
+	Label string  `json:"label"`
+	Error float64 `json:"error"`
+}
+
+type TransformResult struct {
+	Transform sph.Similarity `json:"transform"`
+	Residuals []Residual     `json:"residuals"`
+	RMS       float64        `json:"rms"`
+}
+
+// Known length (in millimetres) between two landmarks
+type ScaleBar struct {
+	Label  string  `json:"label"`
+	Left   string  `json:"left"`
+	Right  string  `json:"right"`
+	Length float64 `json:"length"`
+}
+
+type ScaleResult struct {
+	Scale     float64    `json:"scale"`
+	Residuals []Residual `json:"residuals"`
+	RMS       float64    `json:"rms"`
 }
 
 type VirtualCameraImage struct {
@@ -64,11 +137,184 @@ type ImportTemplate struct {
 	Files []ImportFile
 }
 
+// Export landmarks structs
+
+type ExportFile struct {
+	Label     string
+	Extension string
+}
+
+type ExportForm struct {
+	Name      string `json:"name"`
+	Label     string `json:"label"`
+	Extension string `json:"extension"`
+}
+
+// Landmarks of a specimen given to the writers
+type LandmarksExport struct {
+	Landmarks ExportJSON
+	Specimen  string
+	Project   *project
+}
+
+// Landmark protocol, the order of its landmarks defines their homology across specimens
+type Protocol struct {
+	Name        string             `json:"name"`
+	Description string             `json:"description"`
+	Landmarks   []ProtocolLandmark `json:"landmarks"`
+	Curves      []ProtocolCurve    `json:"curves"`
+}
+
+type ProtocolLandmark struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Image       string `json:"image"`
+	Color       string `json:"color"`
+	Required    bool   `json:"required"`
+}
+
+// Curve of sliding semilandmarks between two landmarks of the protocol
+type ProtocolCurve struct {
+	Name          string `json:"name"`
+	Description   string `json:"description"`
+	Start         string `json:"start"`
+	End           string `json:"end"`
+	Semilandmarks int    `json:"semilandmarks"`
+}
+
+type ProtocolValidation struct {
+	Valid            bool     `json:"valid"`
+	MissingRequired  []string `json:"missingRequired"`
+	MissingOptional  []string `json:"missingOptional"`
+	Unknown          []string `json:"unknown"`
+	UnexpectedColors []string `json:"unexpectedColors"`
+}
+
+type BatchSpecimen struct {
+	Specimen string   `json:"specimen"`
+	Project  string   `json:"project"`
+	Session  string   `json:"session"`
+	Protocol string   `json:"protocol"`
+	Missing  []string `json:"missing"`
+	// why the specimen doesn't follow the template, it is left out of the exports
+	Mismatch string `json:"mismatch"`
+}
+
+type BatchReport struct {
+	Output    string          `json:"output"`
+	Template  []string        `json:"template"`
+	Specimens []BatchSpecimen `json:"specimens"`
+	Skipped   []string        `json:"skipped"`
+}
+
+type ProcrustesSpecimen struct {
+	Specimen     string  `json:"specimen"`
+	Project      string  `json:"project"`
+	CentroidSize float64 `json:"centroidSize"`
+	Distance     float64 `json:"distance"`
+	Outlier      bool    `json:"outlier"`
+}
+
+type ProcrustesReport struct {
+	Output     string               `json:"output"`
+	Template   []string             `json:"template"`
+	Specimens  []ProcrustesSpecimen `json:"specimens"`
+	Excluded   []BatchSpecimen      `json:"excluded"`
+	Skipped    []string             `json:"skipped"`
+	Mean       [][]float64          `json:"mean"`
+	Iterations int                  `json:"iterations"`
+}
+
+// Dispersion of a landmark placed in several sessions
+type LandmarkError struct {
+	Label    string    `json:"label"`
+	Sessions int       `json:"sessions"`
+	Mean     []float64 `json:"mean"`
+	RMS      float64   `json:"rms"`
+	Max      float64   `json:"max"`
+}
+
+// Dispersion of a distance measured in several sessions
+type DistanceError struct {
+	Label    string  `json:"label"`
+	Sessions int     `json:"sessions"`
+	Mean     float64 `json:"mean"`
+	SD       float64 `json:"sd"`
+	CV       float64 `json:"cv"`
+	Min      float64 `json:"min"`
+	Max      float64 `json:"max"`
+}
+
+type MeasurementErrorReport struct {
+	Output    string          `json:"output"`
+	Sessions  []string        `json:"sessions"`
+	Landmarks []LandmarkError `json:"landmarks"`
+	Distances []DistanceError `json:"distances"`
+}
+
+// ICC(2,1) of a distance measured in the same sessions of several specimens
+type DistanceRepeatability struct {
+	Label     string `json:"label"`
+	Specimens int    `json:"specimens"`
+	// null if fewer than 2 specimens have the distance in every session
+	ICC *float64 `json:"icc"`
+}
+
+type RepeatabilityReport struct {
+	Output    string                  `json:"output"`
+	Specimens []string                `json:"specimens"`
+	Sessions  []string                `json:"sessions"`
+	Distances []DistanceRepeatability `json:"distances"`
+}
+
+// Pose of a landmark found on an image by template matching
+type Match struct {
+	Image string  `json:"image"`
+	Pose  sph.Pos `json:"pose"`
+	Score float64 `json:"score"`
+	// why the view was skipped (image that can't be read), the match has no pose then
+	Error string `json:"error,omitempty"`
+}
+
+type Propagation struct {
+	Position []float64          `json:"position"`
+	Poses    map[string]sph.Pos `json:"poses"`
+	Matches  []Match            `json:"matches"`
+}
+
+// Projection of a 3D point on an image
+type Reprojection struct {
+	Pose     sph.Pos `json:"pose"`
+	InFront  bool    `json:"inFront"`
+	Inside   bool    `json:"inside"`
+	NearEdge bool    `json:"nearEdge"`
+}
+
+// Image where a 3D point is visible
+type ViewScore struct {
+	Image       string          `json:"image"`
+	Coordinates sph.Coordinates `json:"coordinates"`
+	Pose        sph.Pos         `json:"pose"`
+	Angle       float64         `json:"angle"`
+	Distance    float64         `json:"distance"`
+	Score       float64         `json:"score"`
+}
+
+type Snap struct {
+	Pose       sph.Pos `json:"pose"`
+	Confidence float64 `json:"confidence"`
+	Feature    string  `json:"feature"`
+}
+
 // Import landmarks JSON
 type ExportJSON struct {
 	ScaleFactor float64                 `json:"scaleFactor"`
 	Landmarks   map[string]LandmarkJSON `json:"landmarks"`
 	Distances   []DistanceJSON          `json:"distances"`
+	Metadata    *Metadata               `json:"metadata,omitempty"`
+	Protocol    *Protocol               `json:"protocol,omitempty"`
+	Measures    []MeasureJSON           `json:"measures,omitempty"`
+	Curves      []CurveJSON             `json:"curves,omitempty"`
 }
 
 type LandmarkJSON struct {
@@ -89,6 +335,28 @@ type DistanceJSON struct {
 	Right string `json:"right"`
 }
 
+// Measure between landmarks (referenced by key or label), its value is computed from their positions
+type MeasureJSON struct {
+	Label     string   `json:"label"`
+	Type      string   `json:"type"`
+	Landmarks []string `json:"landmarks"`
+	// null if a landmark is missing or the measure can't be computed
+	Value   *float64 `json:"value"`
+	Missing []string `json:"missing"`
+	// why the measure can't be computed
+	Error string `json:"error,omitempty"`
+}
+
+// Curve through landmarks (referenced by key or label) clicked along an edge,
+// resampled into equally spaced semilandmarks between its first and last landmarks
+type CurveJSON struct {
+	Label         string      `json:"label"`
+	Landmarks     []string    `json:"landmarks"`
+	Semilandmarks int         `json:"semilandmarks"`
+	Points        [][]float64 `json:"points"`
+	Missing       []string    `json:"missing"`
+}
+
 // Import landmarks CSV
 type LandmarkCSV struct {
 	Label     string `json:"label"`
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+
+	sph "sphaeroptica.be/photogrammetry/photogrammetry"
+)
+
+// Shortcuts created at import, they assume the specimen was aligned to the rig
+func defaultCommands(latMin float64, latMax float64) (map[string]sph.Coordinates, []string) {
+	return map[string]sph.Coordinates{
+		"FRONT":    {Longitude: 0, Latitude: 0},
+		"POST":     {Longitude: 180, Latitude: 0},
+		"LEFT":     {Longitude: 90, Latitude: 0},
+		"RIGHT":    {Longitude: -90, Latitude: 0},
+		"SUPERIOR": {Longitude: 0, Latitude: latMin},
+		"INFERIOR": {Longitude: 180, Latitude: latMax},
+	}, []string{"FRONT", "POST", "LEFT", "RIGHT", "SUPERIOR", "INFERIOR"}
+}
+
+// commandsOrder returns the names of the views in their order,
+// views missing from CommandsOrder (older projects) are sorted at the end
+func (p *project) commandsOrder() []string {
+	order := make([]string, 0, len(p.Commands))
+	for _, name := range p.CommandsOrder {
+		if _, ok := p.Commands[name]; ok && !slices.Contains(order, name) {
+			order = append(order, name)
+		}
+	}
+	missing := make([]string, 0)
+	for name := range p.Commands {
+		if !slices.Contains(order, name) {
+			missing = append(missing, name)
+		}
+	}
+	sort.Strings(missing)
+	return append(order, missing...)
+}
+
+func (p *project) views() []View {
+	views := make([]View, 0, len(p.Commands))
+	for _, name := range p.commandsOrder() {
+		views = append(views, View{Name: name, Coordinates: p.Commands[name]})
+	}
+	return views
+}
+
+// Get the ordered views of the project
+func (a *App) Views(projectFile string) ([]View, error) {
+	p, release, err := a.acquire(projectFile)
+	if err != nil {
+		return []View{}, newError(ERROR_PROJECT, err)
+	}
+	defer release()
+
+	return p.views(), nil
+}
+
+// Create a view (or move an existing one) at the given orientation
+func (a *App) SaveView(projectFile string, name string, coordinates sph.Coordinates) ([]View, error) {
+	return a.editViews(projectFile, func(p *project) error {
+		if name == "" {
+			return fmt.Errorf("a view needs a name")
+		}
+		if _, ok := p.Commands[name]; !ok {
+			p.CommandsOrder = append(p.commandsOrder(), name)
+		}
+		if p.Commands == nil {
+			p.Commands = make(map[string]sph.Coordinates)
+		}
+		p.Commands[name] = coordinates
+		return nil
+	})
+}
+
+func (a *App) RenameView(projectFile string, name string, newName string) ([]View, error) {
+	return a.editViews(projectFile, func(p *project) error {
+		coordinates, ok := p.Commands[name]
+		if !ok {
+			return fmt.Errorf("unknown view %s", name)
+		}
+		if _, exists := p.Commands[newName]; exists || newName == "" {
+			return fmt.Errorf("invalid view name %q", newName)
+		}
+		order := p.commandsOrder()
+		order[slices.Index(order, name)] = newName
+		delete(p.Commands, name)
+		p.Commands[newName] = coordinates
+		p.CommandsOrder = order
+		return nil
+	})
+}
+
+func (a *App) DeleteView(projectFile string, name string) ([]View, error) {
+	return a.editViews(projectFile, func(p *project) error {
+		if _, ok := p.Commands[name]; !ok {
+			return fmt.Errorf("unknown view %s", name)
+		}
+		order := p.commandsOrder()
+		p.CommandsOrder = slices.Delete(order, slices.Index(order, name), slices.Index(order, name)+1)
+		delete(p.Commands, name)
+		return nil
+	})
+}
+
+// Reorder the views, names missing from the list keep their relative order at the end
+func (a *App) ReorderViews(projectFile string, names []string) ([]View, error) {
+	return a.editViews(projectFile, func(p *project) error {
+		for _, name := range names {
+			if _, ok := p.Commands[name]; !ok {
+				return fmt.Errorf("unknown view %s", name)
+			}
+		}
+		order := slices.Clone(names)
+		for _, name := range p.commandsOrder() {
+			if !slices.Contains(order, name) {
+				order = append(order, name)
+			}
+		}
+		p.CommandsOrder = order
+		return nil
+	})
+}
+
+// editViews applies the edit to the project, saves it and returns the views
+func (a *App) editViews(projectFile string, edit func(p *project) error) ([]View, error) {
+	p, release, err := a.acquire(projectFile)
+	if err != nil {
+		return []View{}, newError(ERROR_PROJECT, err)
+	}
+	defer release()
+
+	if err := edit(p); err != nil {
+		return p.views(), newError(ERROR_INVALID_ARGUMENT, err)
+	}
+	if err := saveProjectFile(projectFile, p); err != nil {
+		return p.views(), newError(ERROR_PROJECT, err)
+	}
+	return p.views(), nil
+}